@@ -2,12 +2,15 @@ package stream
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"runtime"
 	"sort"
 	"sync"
 )
 
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 // Stream Stream
 type Stream interface {
 	/*
@@ -30,12 +33,30 @@ type Stream interface {
 	Filter(filter func(elem interface{}) bool) Stream
 	// 根据mapper func将stream中的elem对象转化成另一种对象
 	Map(mapper func(elem interface{}) interface{}) Stream
+	// 根据mapper func将stream中的每个elem转化成多个对象，并将结果打平
+	FlatMap(mapper func(elem interface{}) []interface{}) Stream
 	// 跳过前n条记录
 	Offset(n int) Stream
 	// 取前n条记录
 	Limit(n int) Stream
 	// 根据sorter的排序规则进行排序，sorter的结果为true则为降序，为false为升序
+	// 排序是稳定的，sorter认为相等的元素保持原有顺序
 	Sorted(sorter func(elem1, elem2 interface{}) bool) Stream
+	// 和Sorted相同，显式地表明依赖排序的稳定性，sorter认为相等的元素一定保持原有顺序
+	SortedStable(sorter func(elem1, elem2 interface{}) bool) Stream
+
+	/*
+	 * 以下Typed方法和上面对应的方法相同，但接受声明了具体类型的函数，不需要在函数中进行转型断言，例如：
+	 * 		streamer.FilterTyped(func(elem testUser) bool { return elem.Age >= 18 })
+	 * 函数签名不正确时立刻panic；元素类型和函数的参数类型不一致时，终结操作返回error
+	 */
+
+	// filter参数应为 func (elem T) bool，T为上游数据类型
+	FilterTyped(filter interface{}) Stream
+	// mapper参数应为 func (elem T) O，T为上游数据类型，O为产出的新数据类型
+	MapTyped(mapper interface{}) Stream
+	// sorter参数应为 func (elem1, elem2 T) bool，T为上游数据类型
+	SortedTyped(sorter interface{}) Stream
 
 	/*
 	 * 终结操作，例如求值，会立刻执行。并且会执行累加的惰性操作。
@@ -43,10 +64,19 @@ type Stream interface {
 
 	// 遍历所有结果，对每个结果执行希望的op func
 	Foreach(op func(elem interface{}) error) error
+	// 和Foreach相同，op参数应为 func (elem T) 或 func (elem T) error，T为上游数据类型
+	ForeachTyped(op interface{}) error
 	// 将结果读取出来，调用者根据stream中的元素类型，传入相应的slice pointer
 	Scan(result interface{}) error
 	// 根据getKey func获取key，并做聚合。聚合结果由result带出。
 	GroupBy(getKey func(elem interface{}) interface{}, result interface{}) error
+	// 和GroupBy相同，getKey参数应为 func (elem T) K，T为上游数据类型，K为result的key类型
+	GroupByTyped(getKey interface{}, result interface{}) error
+	// 根据accumulator两两聚合，第一个元素作为初始值，结果由result带出，没有元素时不修改result
+	// 注意：和streamv3的Reduce不同，result原有的值不会作为初始值参与聚合，只有一个元素时直接将其带出
+	Reduce(accumulator func(elem1, elem2 interface{}) interface{}, result interface{}) error
+	// 根据getKey func获取key，将结果转化为map，key重复时后面的元素覆盖前面的元素，结果由result带出
+	ToMap(getKey func(elem interface{}) interface{}, result interface{}) error
 	// 获取结果中的第一个
 	First(result interface{}) (bool, error)
 	// 获取结果中的最后一个
@@ -66,6 +96,7 @@ type Streamer struct {
 	parallel     int
 	filterFunc   func(elem interface{}) bool
 	mapFunc      func(elem interface{}) interface{}
+	flatMapFunc  func(elem interface{}) []interface{}
 	sortFunc     func(first, second interface{}) bool
 	offset       int
 	limit        int
@@ -142,6 +173,21 @@ func (streamer *Streamer) Map(mapper func(elem interface{}) interface{}) *Stream
 	}
 }
 
+// FlatMap 转化规则，mapper返回的多个对象会被打平后继续进入stream
+// 若调用者在mapper中进行转型断言，需要调用者自己保证stream中的元素可以被转型断言
+func (streamer *Streamer) FlatMap(mapper func(elem interface{}) []interface{}) *Streamer {
+	return &Streamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		flatMapFunc:  mapper,
+		sortFunc:     nil,
+		offset:       streamer.offset,
+		limit:        streamer.limit,
+	}
+}
+
 // Limit 取前n条记录，惰性操作，只在执行了终结操作时起作用
 func (streamer *Streamer) Limit(n int) *Streamer {
 	if n <= 0 {
@@ -174,7 +220,7 @@ func (streamer *Streamer) Offset(n int) *Streamer {
 	}
 }
 
-// Sorted 排序
+// Sorted 稳定排序，scan中使用sort.SliceStable实现，SortedStable依赖这一点，修改时需保持稳定
 func (streamer *Streamer) Sorted(sorter func(elem1, elem2 interface{}) bool) *Streamer {
 	return &Streamer{
 		lastStreamer: streamer,
@@ -187,6 +233,44 @@ func (streamer *Streamer) Sorted(sorter func(elem1, elem2 interface{}) bool) *St
 	}
 }
 
+// SortedStable 稳定排序，Sorted本身保证稳定，因此直接复用Sorted
+func (streamer *Streamer) SortedStable(sorter func(elem1, elem2 interface{}) bool) *Streamer {
+	return streamer.Sorted(sorter)
+}
+
+// FilterTyped 使用声明了具体类型的filter过滤
+func (streamer *Streamer) FilterTyped(filter interface{}) *Streamer {
+	fv := checkTypedFunc("filter", filter, 1)
+	if fv.Type().NumOut() != 1 || fv.Type().Out(0).Kind() != reflect.Bool {
+		panic(fmt.Errorf("filter's return-val type should be bool"))
+	}
+	return streamer.Filter(func(elem interface{}) bool {
+		return callTyped("filter", fv, elem)[0].Bool()
+	})
+}
+
+// MapTyped 使用声明了具体类型的mapper转化
+func (streamer *Streamer) MapTyped(mapper interface{}) *Streamer {
+	fv := checkTypedFunc("mapper", mapper, 1)
+	if fv.Type().NumOut() != 1 {
+		panic(fmt.Errorf("mapper's output number must equals 1, not %d", fv.Type().NumOut()))
+	}
+	return streamer.Map(func(elem interface{}) interface{} {
+		return callTyped("mapper", fv, elem)[0].Interface()
+	})
+}
+
+// SortedTyped 使用声明了具体类型的sorter稳定排序
+func (streamer *Streamer) SortedTyped(sorter interface{}) *Streamer {
+	fv := checkTypedFunc("sorter", sorter, 2)
+	if fv.Type().NumOut() != 1 || fv.Type().Out(0).Kind() != reflect.Bool {
+		panic(fmt.Errorf("sorter's return-val type should be bool"))
+	}
+	return streamer.Sorted(func(elem1, elem2 interface{}) bool {
+		return callTyped("sorter", fv, elem1, elem2)[0].Bool()
+	})
+}
+
 // Foreach 遍历streamer中的每个元素
 func (streamer *Streamer) Foreach(op func(elem interface{}) error) error {
 	result, err := streamer.scan()
@@ -202,6 +286,23 @@ func (streamer *Streamer) Foreach(op func(elem interface{}) error) error {
 	return nil
 }
 
+// ForeachTyped 使用声明了具体类型的op遍历每个元素
+func (streamer *Streamer) ForeachTyped(op interface{}) (err error) {
+	fv := checkTypedFunc("op", op, 1)
+	ft := fv.Type()
+	if ft.NumOut() > 1 || (ft.NumOut() == 1 && ft.Out(0) != errorType) {
+		panic(fmt.Errorf("op's return-val type should be empty or error"))
+	}
+	defer recoverError(&err)
+	return streamer.Foreach(func(elem interface{}) error {
+		out := callTyped("op", fv, elem)
+		if len(out) == 1 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+		return nil
+	})
+}
+
 // Scan 将结果带出
 func (streamer *Streamer) Scan(result interface{}) error {
 	val := reflect.ValueOf(result)
@@ -261,6 +362,77 @@ func (streamer *Streamer) GroupBy(getKey func(elem interface{}) interface{}, res
 	return streamer.groupBy(getKey, scanResult, &val)
 }
 
+// GroupByTyped 使用声明了具体类型的getKey获取key并聚合
+func (streamer *Streamer) GroupByTyped(getKey interface{}, result interface{}) (err error) {
+	fv := checkTypedFunc("getKey", getKey, 1)
+	if fv.Type().NumOut() != 1 {
+		panic(fmt.Errorf("getKey's output number must equals 1, not %d", fv.Type().NumOut()))
+	}
+	defer recoverError(&err)
+	return streamer.GroupBy(func(elem interface{}) interface{} {
+		return callTyped("getKey", fv, elem)[0].Interface()
+	}, result)
+}
+
+// Reduce 根据accumulator两两聚合，以第一个元素作为初始值，忽略result原有的值（streamv3的Reduce会将其作为初始值）
+func (streamer *Streamer) Reduce(accumulator func(elem1, elem2 interface{}) interface{}, result interface{}) (err error) {
+	if accumulator == nil {
+		return errors.New("accumulator func can't be nil")
+	}
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr {
+		return errors.New("result must be a pointer")
+	}
+	scanResult, err := streamer.scan()
+	if err != nil {
+		return err
+	}
+	if len(scanResult) == 0 {
+		return nil
+	}
+	defer recoverError(&err)
+	acc := scanResult[0]
+	for i := 1; i < len(scanResult); i++ {
+		acc = accumulator(acc, scanResult[i])
+	}
+	accVal := reflect.ValueOf(acc)
+	if !accVal.Type().AssignableTo(val.Elem().Type()) {
+		return fmt.Errorf("reduce result's type is %s, but result's type is %s", accVal.Type(), val.Elem().Type())
+	}
+	val.Elem().Set(accVal)
+	return nil
+}
+
+// ToMap 根据getKey函数获取key，并将结果转化为result map带回
+func (streamer *Streamer) ToMap(getKey func(elem interface{}) interface{}, result interface{}) (err error) {
+	if getKey == nil {
+		return errors.New("getKey func can't be nil")
+	}
+	val := reflect.ValueOf(result)
+	if val.Kind() == reflect.Ptr {
+		if val.Elem().Kind() != reflect.Map {
+			return errors.New("result must be map or map pointer")
+		}
+		val = val.Elem()
+		// nil map init
+		if val.IsNil() {
+			val.Set(reflect.MakeMap(val.Type()))
+		}
+	}
+	if val.Kind() != reflect.Map {
+		return errors.New("result must be map or map pointer")
+	}
+	scanResult, err := streamer.scan()
+	if err != nil {
+		return err
+	}
+	defer recoverError(&err)
+	for i := 0; i < len(scanResult); i++ {
+		val.SetMapIndex(reflect.ValueOf(getKey(scanResult[i])), reflect.ValueOf(scanResult[i]))
+	}
+	return nil
+}
+
 // First 取第一个结果
 func (streamer *Streamer) First(result interface{}) (exist bool, err error) {
 	val := reflect.ValueOf(result)
@@ -307,7 +479,9 @@ func (streamer *Streamer) IndexAt(index int, result interface{}) (bool, error) {
  */
 
 // scan 内部实现，用于其他方法复用
-func (streamer *Streamer) scan() ([]interface{}, error) {
+// filter/mapper/sorter中的panic会被转换为error返回
+func (streamer *Streamer) scan() (result []interface{}, err error) {
+	defer recoverError(&err)
 	streamerList := []*Streamer{}
 	lastStreamer := streamer
 	for ; lastStreamer != nil; lastStreamer = lastStreamer.lastStreamer {
@@ -318,13 +492,22 @@ func (streamer *Streamer) scan() ([]interface{}, error) {
 	newData = append(newData, data...)
 	for i := len(streamerList) - 1; i >= 0; i-- {
 		if streamerList[i].filterFunc != nil {
-			newData = streamerList[i].filter(newData)
+			if newData, err = streamerList[i].filter(newData); err != nil {
+				return nil, err
+			}
+		}
+		if streamerList[i].flatMapFunc != nil {
+			if newData, err = streamerList[i].flatMap(newData); err != nil {
+				return nil, err
+			}
 		}
 		if streamerList[i].mapFunc != nil {
-			newData = streamerList[i]._map(newData)
+			if newData, err = streamerList[i]._map(newData); err != nil {
+				return nil, err
+			}
 		}
 		if streamerList[i].sortFunc != nil {
-			sort.Slice(newData, func(first, second int) bool {
+			sort.SliceStable(newData, func(first, second int) bool {
 				return streamerList[i].sortFunc(newData[first], newData[second])
 			})
 		}
@@ -343,8 +526,9 @@ func (streamer *Streamer) scan() ([]interface{}, error) {
 }
 
 // filter 内部实现，用于其他方法复用
-func (streamer *Streamer) filter(data []interface{}) (result []interface{}) {
+func (streamer *Streamer) filter(data []interface{}) (result []interface{}, err error) {
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	wg.Add(streamer.parallel)
 	batch := len(data) / streamer.parallel
 	results := make([][]interface{}, streamer.parallel, streamer.parallel)
@@ -356,6 +540,11 @@ func (streamer *Streamer) filter(data []interface{}) (result []interface{}) {
 		}
 		go func(goroutineID, start, end int) {
 			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						err = panicToError(r)
+					})
+				}
 				wg.Done()
 			}()
 			res := []interface{}{}
@@ -368,15 +557,20 @@ func (streamer *Streamer) filter(data []interface{}) (result []interface{}) {
 		}(i, start, end)
 	}
 	wg.Wait()
+	// 内部多个goroutine并行，将内部panic作为error返回
+	if err != nil {
+		return nil, err
+	}
 	for i := 0; i < len(results); i++ {
 		result = append(result, results[i]...)
 	}
-	return result
+	return result, nil
 }
 
 // _map 内部实现，用于其他方法复用
-func (streamer *Streamer) _map(data []interface{}) (result []interface{}) {
+func (streamer *Streamer) _map(data []interface{}) (result []interface{}, err error) {
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	wg.Add(streamer.parallel)
 	batch := len(data) / streamer.parallel
 	results := make([][]interface{}, streamer.parallel, streamer.parallel)
@@ -388,6 +582,11 @@ func (streamer *Streamer) _map(data []interface{}) (result []interface{}) {
 		}
 		go func(goroutineID, start, end int) {
 			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						err = panicToError(r)
+					})
+				}
 				wg.Done()
 			}()
 			res := []interface{}{}
@@ -398,10 +597,54 @@ func (streamer *Streamer) _map(data []interface{}) (result []interface{}) {
 		}(i, start, end)
 	}
 	wg.Wait()
+	// 内部多个goroutine并行，将内部panic作为error返回
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(results); i++ {
+		result = append(result, results[i]...)
+	}
+	return result, nil
+}
+
+// flatMap 内部实现，用于其他方法复用
+func (streamer *Streamer) flatMap(data []interface{}) (result []interface{}, err error) {
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	wg.Add(streamer.parallel)
+	batch := len(data) / streamer.parallel
+	results := make([][]interface{}, streamer.parallel, streamer.parallel)
+	for i := 0; i < streamer.parallel; i++ {
+		start := i * batch
+		end := start + batch
+		if i == streamer.parallel-1 && end < len(data) {
+			end = len(data)
+		}
+		go func(goroutineID, start, end int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						err = panicToError(r)
+					})
+				}
+				wg.Done()
+			}()
+			res := []interface{}{}
+			for i := start; i < end; i++ {
+				res = append(res, streamer.flatMapFunc(data[i])...)
+			}
+			results[goroutineID] = res
+		}(i, start, end)
+	}
+	wg.Wait()
+	// 内部多个goroutine并行，将内部panic作为error返回
+	if err != nil {
+		return nil, err
+	}
 	for i := 0; i < len(results); i++ {
 		result = append(result, results[i]...)
 	}
-	return result
+	return result, nil
 }
 
 // groupBy GroupBy内部实现，支持并行
@@ -411,8 +654,8 @@ func (streamer *Streamer) groupBy(getKey func(elem interface{}) interface{}, sca
 	val := *valPointer
 	batch := len(scanResult) / streamer.parallel
 	// collect results from different worker goroutine
-	// make the cap equals streamer.parallel, and use iteration index as goroutineID to avoid concurrent problem
-	resultCollection := make(map[int]map[interface{}][]interface{}, streamer.parallel)
+	// pre-allocate one slot per goroutine, and use iteration index as goroutineID to avoid concurrent map writes
+	resultCollection := make([]map[interface{}][]interface{}, streamer.parallel)
 
 	for i := 0; i < streamer.parallel; i++ {
 		start := i * batch
@@ -458,6 +701,49 @@ func (streamer *Streamer) groupBy(getKey func(elem interface{}) interface{}, sca
 	return nil
 }
 
+// checkTypedFunc 校验fn是参数个数为numIn的函数
+func checkTypedFunc(name string, fn interface{}, numIn int) reflect.Value {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	if fv.Type().NumIn() != numIn {
+		panic(fmt.Errorf("%s's args number must equals %d, not %d", name, numIn, fv.Type().NumIn()))
+	}
+	return fv
+}
+
+// callTyped 校验元素的类型后调用fv，类型不一致时panic
+func callTyped(name string, fv reflect.Value, elems ...interface{}) []reflect.Value {
+	args := make([]reflect.Value, len(elems))
+	for i := 0; i < len(elems); i++ {
+		in := fv.Type().In(i)
+		args[i] = reflect.ValueOf(elems[i])
+		if !args[i].IsValid() {
+			args[i] = reflect.Zero(in)
+		}
+		if !args[i].Type().AssignableTo(in) {
+			panic(fmt.Errorf("elem's type is %s, but %s's args type is %s", args[i].Type(), name, in))
+		}
+	}
+	return fv.Call(args)
+}
+
+// panicToError 将recover得到的值转换为error
+func panicToError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("panic: %v", r)
+}
+
+// recoverError 将panic转换为error写入err，需要直接被defer调用
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = panicToError(r)
+	}
+}
+
 // indexAt IndexAt的内部实现
 func (streamer *Streamer) indexAt(index int, scanResult []interface{}, result interface{}) (bool, error) {
 	val := reflect.ValueOf(result).Elem()
@@ -268,3 +268,136 @@ func TestStreamer_Count(t *testing.T) {
 	}
 	assertEquals(t, len(testData), count)
 }
+
+func TestStreamer_SortedStable(t *testing.T) {
+	data := make([]testUser, 100)
+	for i := 0; i < len(data); i++ {
+		data[i] = testUser{ID: i, Age: i % 3}
+	}
+	s, err := NewStreamerWithData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := []testUser{}
+	err = s.SortedStable(func(elem1, elem2 interface{}) bool {
+		return elem1.(testUser).Age < elem2.(testUser).Age
+	}).Scan(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(result); i++ {
+		if result[i-1].Age == result[i].Age && result[i-1].ID > result[i].ID {
+			t.Fatalf("expected stable order, but %v is before %v", result[i-1], result[i])
+		}
+	}
+}
+
+func TestStreamer_Typed(t *testing.T) {
+	result := []int{}
+	err := streamer.FilterTyped(func(elem testUser) bool {
+		return elem.Age >= 18
+	}).MapTyped(func(elem testUser) int {
+		return elem.ID
+	}).SortedTyped(func(id1, id2 int) bool {
+		return id1 > id2
+	}).Scan(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, result, []int{4, 3})
+
+	names := []string{}
+	err = streamer.ForeachTyped(func(elem testUser) {
+		names = append(names, elem.Name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, names, []string{"zhangsan", "lisi", "wangwu", "zhaoliu"})
+
+	groups := map[int][]testUser{}
+	err = streamer.GroupByTyped(func(elem testUser) int {
+		return elem.Age
+	}, &groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, len(groups[15]), 2)
+
+	err = streamer.MapTyped(func(elem testUser) int {
+		return elem.ID
+	}).FilterTyped(func(elem testUser) bool {
+		return true
+	}).Scan(&result)
+	if err == nil || !strings.Contains(err.Error(), "elem's type is int, but filter's args type is stream.testUser") {
+		t.Errorf("expected type mismatch error, but got %v", err)
+	}
+	err = streamer.ForeachTyped(func(elem int) {})
+	if err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
+
+func TestStreamer_FlatMap(t *testing.T) {
+	result := []string{}
+	err := streamer.FlatMap(func(elem interface{}) []interface{} {
+		parts := strings.Split(elem.(testUser).Email, "@")
+		return []interface{}{parts[0], parts[1]}
+	}).Limit(4).Scan(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, result, []string{"zhangsan", "xxx.com", "lisi", "xxx.com"})
+}
+
+func TestStreamer_Reduce(t *testing.T) {
+	total := 0
+	err := streamer.Map(func(elem interface{}) interface{} {
+		return elem.(testUser).Age
+	}).Reduce(func(elem1, elem2 interface{}) interface{} {
+		return elem1.(int) + elem2.(int)
+	}, &total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, total, 75)
+
+	// result原有的值不参与聚合
+	seeded := 100
+	err = streamer.Map(func(elem interface{}) interface{} {
+		return elem.(testUser).Age
+	}).Reduce(func(elem1, elem2 interface{}) interface{} {
+		return elem1.(int) + elem2.(int)
+	}, &seeded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, seeded, 75)
+
+	name := ""
+	err = streamer.Reduce(func(elem1, elem2 interface{}) interface{} {
+		return elem1
+	}, &name)
+	if err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
+
+func TestStreamer_ToMap(t *testing.T) {
+	var result map[int]testUser
+	err := streamer.ToMap(func(elem interface{}) interface{} {
+		return elem.(testUser).ID
+	}, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, len(result), 4)
+	assertEquals(t, result[3].Name, "wangwu")
+
+	err = streamer.ToMap(func(elem interface{}) interface{} {
+		return elem.(testUser).Name
+	}, &result)
+	if err == nil {
+		t.Error("expected key type mismatch error")
+	}
+}
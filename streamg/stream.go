@@ -0,0 +1,357 @@
+//go:build go1.18
+
+// Package streamg 基于泛型实现的stream，操作的参数类型在编译期检查，处理每个元素时没有反射的开销
+// 和streamv3一样，所有的中间操作都是惰性的，只有在执行终结操作时才会执行整条链
+package streamg
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// Stream 泛型数据流，每个中间操作都返回新的Stream，不会修改当前的Stream
+type Stream[T any] struct {
+	// source 执行之前的所有操作并返回结果
+	source func() []T
+	// parallel 之后的操作使用的并行度，默认继承上一个Stream
+	parallel int
+	// offset limit 和streamv3一样传递给之后的Stream，只在终结操作时作用于最终结果
+	offset int
+	limit  int
+}
+
+// PanicError 并行执行时goroutine中发生的panic，会在主goroutine中重新panic，和streamv3.PanicError相同
+// Value为原始的panic值，Stack为发生panic的goroutine的调用栈；Value为error时可以通过errors.As/errors.Is取出
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Of 以data为数据源创建Stream，创建时不会复制data
+func Of[T any](data []T) Stream[T] {
+	return Stream[T]{
+		source: func() []T {
+			return data
+		},
+		parallel: 1,
+	}
+}
+
+// then 以当前Stream为上游创建新的Stream，op处理上游的结果
+func (s Stream[T]) then(op func(data []T) []T) Stream[T] {
+	return Stream[T]{
+		source: func() []T {
+			return op(s.source())
+		},
+		parallel: s.parallel,
+		offset:   s.offset,
+		limit:    s.limit,
+	}
+}
+
+// Parallel 设置之后的操作的并行度，并行度限制在[1, 2 * cpu_num]之间
+// 和streamv3一样，并行时会将数据切分成parallel批，每个goroutine处理一批，结果保持原有顺序
+func (s Stream[T]) Parallel(parallel int) Stream[T] {
+	s.parallel = fixParallel(parallel)
+	return s
+}
+
+// Filter 只保留pred返回true的元素
+func (s Stream[T]) Filter(pred func(item T) bool) Stream[T] {
+	parallel := s.parallel
+	return s.then(func(data []T) []T {
+		return batch(data, parallel, func(part []T) []T {
+			res := make([]T, 0, len(part))
+			for i := 0; i < len(part); i++ {
+				if pred(part[i]) {
+					res = append(res, part[i])
+				}
+			}
+			return res
+		})
+	})
+}
+
+// Peek 对每个元素执行op，不修改元素
+func (s Stream[T]) Peek(op func(item T)) Stream[T] {
+	return s.then(func(data []T) []T {
+		for i := 0; i < len(data); i++ {
+			op(data[i])
+		}
+		return data
+	})
+}
+
+// Sorted 按less稳定排序，不会修改数据源
+func (s Stream[T]) Sorted(less func(a, b T) bool) Stream[T] {
+	return s.then(func(data []T) []T {
+		sorted := make([]T, len(data))
+		copy(sorted, data)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return less(sorted[i], sorted[j])
+		})
+		return sorted
+	})
+}
+
+// Reverse 将元素逆序
+func (s Stream[T]) Reverse() Stream[T] {
+	return s.then(func(data []T) []T {
+		reversed := make([]T, len(data))
+		for i := 0; i < len(data); i++ {
+			reversed[len(data)-1-i] = data[i]
+		}
+		return reversed
+	})
+}
+
+// Offset 跳过前offset个元素，offset小于等于0时不跳过
+// 和streamv3一样作用于整条链的最终结果，与调用的位置无关（例如 Offset(1).Filter(...) 跳过的是过滤后的第一个元素），多次调用时以最后一次为准
+func (s Stream[T]) Offset(offset int) Stream[T] {
+	s.offset = offset
+	return s
+}
+
+// Limit 只保留前limit个元素，limit小于等于0时不限制
+// 和streamv3一样作用于整条链的最终结果（先Offset再Limit），与调用的位置无关，多次调用时以最后一次为准
+func (s Stream[T]) Limit(limit int) Stream[T] {
+	s.limit = limit
+	return s
+}
+
+// result 执行整条链，并按offset和limit截取最终结果
+func (s Stream[T]) result() []T {
+	data := s.source()
+	if s.offset > 0 {
+		if s.offset > len(data) {
+			return data[len(data):]
+		}
+		data = data[s.offset:]
+	}
+	if s.limit > 0 && s.limit < len(data) {
+		data = data[:s.limit]
+	}
+	return data
+}
+
+// Collect 执行整条链并返回结果
+func (s Stream[T]) Collect() []T {
+	data := s.result()
+	result := make([]T, len(data))
+	copy(result, data)
+	return result
+}
+
+// Scan 将结果写入result，和streamv3的Scan相同
+func (s Stream[T]) Scan(result *[]T) {
+	*result = s.Collect()
+}
+
+// Count 返回元素个数
+func (s Stream[T]) Count() int {
+	return len(s.result())
+}
+
+// Foreach 按顺序对每个元素执行op
+func (s Stream[T]) Foreach(op func(item T)) {
+	data := s.result()
+	for i := 0; i < len(data); i++ {
+		op(data[i])
+	}
+}
+
+// First 返回第一个元素，没有元素时ok为false
+func (s Stream[T]) First() (first T, ok bool) {
+	data := s.result()
+	if len(data) == 0 {
+		return first, false
+	}
+	return data[0], true
+}
+
+// AnyMatch 是否存在pred返回true的元素
+func (s Stream[T]) AnyMatch(pred func(item T) bool) bool {
+	data := s.result()
+	for i := 0; i < len(data); i++ {
+		if pred(data[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllMatch 是否所有元素都使pred返回true，没有元素时返回true
+func (s Stream[T]) AllMatch(pred func(item T) bool) bool {
+	data := s.result()
+	for i := 0; i < len(data); i++ {
+		if !pred(data[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Map 将每个元素转换为mapper的返回值
+// 由于Go的方法不能有额外的类型参数，改变元素类型的操作以函数的形式提供
+func Map[T, O any](s Stream[T], mapper func(item T) O) Stream[O] {
+	return Stream[O]{
+		source: func() []O {
+			return batch(s.source(), s.parallel, func(part []T) []O {
+				res := make([]O, len(part))
+				for i := 0; i < len(part); i++ {
+					res[i] = mapper(part[i])
+				}
+				return res
+			})
+		},
+		parallel: s.parallel,
+		offset:   s.offset,
+		limit:    s.limit,
+	}
+}
+
+// FlatMap 将每个元素转换为多个元素，并按顺序展开
+func FlatMap[T, O any](s Stream[T], mapper func(item T) []O) Stream[O] {
+	return Stream[O]{
+		source: func() []O {
+			return batch(s.source(), s.parallel, func(part []T) []O {
+				res := make([]O, 0, len(part))
+				for i := 0; i < len(part); i++ {
+					res = append(res, mapper(part[i])...)
+				}
+				return res
+			})
+		},
+		parallel: s.parallel,
+		offset:   s.offset,
+		limit:    s.limit,
+	}
+}
+
+// Distinct 按元素本身去重，保留第一次出现的元素
+func Distinct[T comparable](s Stream[T]) Stream[T] {
+	return s.then(func(data []T) []T {
+		seen := make(map[T]struct{}, len(data))
+		res := make([]T, 0, len(data))
+		for i := 0; i < len(data); i++ {
+			if _, ok := seen[data[i]]; ok {
+				continue
+			}
+			seen[data[i]] = struct{}{}
+			res = append(res, data[i])
+		}
+		return res
+	})
+}
+
+// Reduce 从identity开始按顺序用accumulator聚合所有元素
+func Reduce[T, R any](s Stream[T], identity R, accumulator func(acc R, item T) R) R {
+	data := s.result()
+	acc := identity
+	for i := 0; i < len(data); i++ {
+		acc = accumulator(acc, data[i])
+	}
+	return acc
+}
+
+// GroupBy 按keyer的返回值分组，每个分组内保持原有顺序
+func GroupBy[T any, K comparable](s Stream[T], keyer func(item T) K) map[K][]T {
+	data := s.result()
+	groups := map[K][]T{}
+	for i := 0; i < len(data); i++ {
+		key := keyer(data[i])
+		groups[key] = append(groups[key], data[i])
+	}
+	return groups
+}
+
+// ToMap 将元素转换为map，key重复时后面的元素覆盖前面的元素
+func ToMap[T any, K comparable, V any](s Stream[T], keyer func(item T) K, valuer func(item T) V) map[K]V {
+	data := s.result()
+	result := make(map[K]V, len(data))
+	for i := 0; i < len(data); i++ {
+		result[keyer(data[i])] = valuer(data[i])
+	}
+	return result
+}
+
+/*
+ * ============================================
+ * 				inner implement
+ * ============================================
+ */
+
+// batch 将data切分成parallel批，每批由一个goroutine执行op，结果按批次顺序拼接
+// 切分方式和streamv3相同：每批n/parallel个元素，剩余的元素并入最后一批
+func batch[T, O any](data []T, parallel int, op func(part []T) []O) []O {
+	if len(data) < parallel {
+		parallel = len(data)
+	}
+	if parallel <= 1 {
+		return op(data)
+	}
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicError *PanicError
+	wg.Add(parallel)
+	size := len(data) / parallel
+	results := make([][]O, parallel)
+	for i := 0; i < parallel; i++ {
+		start := i * size
+		end := start + size
+		if i == parallel-1 {
+			end = len(data)
+		}
+		go func(goroutineID, start, end int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						// 在defer中取调用栈，才能包含发生panic的位置
+						panicError = &PanicError{Value: r, Stack: debug.Stack()}
+					})
+				}
+				wg.Done()
+			}()
+			results[goroutineID] = op(data[start:end])
+		}(i, start, end)
+	}
+	wg.Wait()
+	// 内部多个goroutine并行，将内部panic放回主goroutine中
+	if panicError != nil {
+		panic(panicError)
+	}
+	total := 0
+	for i := 0; i < len(results); i++ {
+		total += len(results[i])
+	}
+	result := make([]O, 0, total)
+	for i := 0; i < len(results); i++ {
+		result = append(result, results[i]...)
+	}
+	return result
+}
+
+// fixParallel 将并行度限制在[1, 2 * cpu_num]之间
+func fixParallel(parallel int) int {
+	// at least 1 parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	// max parallel = 2 * cpu_num
+	if parallel > runtime.NumCPU()*2 {
+		parallel = runtime.NumCPU() * 2
+	}
+	return parallel
+}
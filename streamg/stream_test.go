@@ -0,0 +1,199 @@
+//go:build go1.18
+
+package streamg
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/caihangui/simple_go_stream/streamv3"
+)
+
+type testUser struct {
+	ID    int
+	Name  string
+	Age   int
+	Email string
+}
+
+var testData = []testUser{
+	{
+		ID:    1,
+		Name:  "zhangsan",
+		Age:   15,
+		Email: "zhangsan@xxx.com",
+	},
+	{
+		ID:    2,
+		Name:  "lisi",
+		Age:   15,
+		Email: "lisi@xxx.com",
+	},
+	{
+		ID:    3,
+		Name:  "wangwu",
+		Age:   20,
+		Email: "wangwu@xxx.com",
+	},
+	{
+		ID:    4,
+		Name:  "zhaoliu",
+		Age:   25,
+		Email: "zhaoliu@xxx.com",
+	},
+}
+
+func assertEquals(t *testing.T, actual, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+// 每个用例分别用streamg和streamv3执行相同的链，结果应一致
+func TestParity(t *testing.T) {
+	for _, parallel := range []int{1, 2, 8} {
+		names := Map(Of(testData).Parallel(parallel).Filter(func(item testUser) bool {
+			return item.Age > 10
+		}).Sorted(func(a, b testUser) bool {
+			return a.Age > b.Age
+		}).Offset(1).Limit(2), func(item testUser) string {
+			return item.Name
+		}).Collect()
+		expected := []string{}
+		streamv3.OfSlice(testData).Parallel(parallel).Filter(func(item testUser) bool {
+			return item.Age > 10
+		}).Sorted(func(a, b testUser) bool {
+			return a.Age > b.Age
+		}).Offset(1).Limit(2).Map(func(item testUser) string {
+			return item.Name
+		}).Scan(&expected)
+		assertEquals(t, names, expected)
+
+		chars := FlatMap(Of(testData).Parallel(parallel), func(item testUser) []string {
+			return strings.Split(item.Name[:2], "")
+		}).Collect()
+		expected = []string{}
+		streamv3.OfSlice(testData).Parallel(parallel).FlatMap(func(item testUser) []string {
+			return strings.Split(item.Name[:2], "")
+		}).Scan(&expected)
+		assertEquals(t, chars, expected)
+
+		ages := Distinct(Map(Of(testData).Parallel(parallel), func(item testUser) int {
+			return item.Age
+		})).Reverse().Collect()
+		expectedAges := []int{}
+		streamv3.OfSlice(testData).Parallel(parallel).Map(func(item testUser) int {
+			return item.Age
+		}).Distinct().Reverse().Scan(&expectedAges)
+		assertEquals(t, ages, expectedAges)
+
+		// Offset/Limit放在Filter之前时，和streamv3一样作用于最终结果
+		ids := Map(Of(testData).Parallel(parallel).Limit(2).Offset(1).Filter(func(item testUser) bool {
+			return item.ID != 2
+		}), func(item testUser) int {
+			return item.ID
+		}).Collect()
+		expectedIDs := []int{}
+		streamv3.OfSlice(testData).Parallel(parallel).Limit(2).Offset(1).Filter(func(item testUser) bool {
+			return item.ID != 2
+		}).Map(func(item testUser) int {
+			return item.ID
+		}).Scan(&expectedIDs)
+		assertEquals(t, ids, expectedIDs)
+		assertEquals(t, ids, []int{3, 4})
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	s := Of(testData)
+	assertEquals(t, s.Count(), 4)
+	first, ok := s.Filter(func(item testUser) bool {
+		return item.Age > 15
+	}).First()
+	assertEquals(t, ok, true)
+	assertEquals(t, first.ID, 3)
+	_, ok = s.Limit(0).Offset(10).First()
+	assertEquals(t, ok, false)
+	assertEquals(t, s.AnyMatch(func(item testUser) bool {
+		return item.Age == 25
+	}), true)
+	assertEquals(t, s.AllMatch(func(item testUser) bool {
+		return item.Age > 15
+	}), false)
+
+	sum := Reduce(s, 0, func(acc int, item testUser) int {
+		return acc + item.Age
+	})
+	assertEquals(t, sum, 75)
+	ids := ""
+	s.Foreach(func(item testUser) {
+		ids += strconv.Itoa(item.ID)
+	})
+	assertEquals(t, ids, "1234")
+
+	groups := GroupBy(s, func(item testUser) int {
+		return item.Age
+	})
+	assertEquals(t, len(groups[15]), 2)
+	assertEquals(t, ToMap(s, func(item testUser) int {
+		return item.ID
+	}, func(item testUser) string {
+		return item.Name
+	})[3], "wangwu")
+
+	result := []testUser{}
+	s.Peek(func(item testUser) {
+		item.Age = 0
+	}).Scan(&result)
+	assertEquals(t, result, testData)
+}
+
+func TestPanicInParallel(t *testing.T) {
+	errBad := errors.New("bad item")
+	defer func() {
+		panicErr, ok := recover().(*PanicError)
+		if !ok {
+			t.Fatal("expected panic with *PanicError")
+		}
+		assertEquals(t, panicErr.Value, errBad)
+		assertEquals(t, errors.Is(panicErr, errBad), true)
+		// 调用栈应包含发生panic的函数
+		assertEquals(t, strings.Contains(string(panicErr.Stack), "TestPanicInParallel"), true)
+	}()
+	Of([]int{1, 2, 3, 4}).Parallel(2).Filter(func(item int) bool {
+		if item == 4 {
+			panic(errBad)
+		}
+		return true
+	}).Count()
+}
+
+func BenchmarkFilterMap(b *testing.B) {
+	data := make([]int, 1000000)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	b.Run("streamg", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Map(Of(data).Filter(func(item int) bool {
+				return item%2 == 0
+			}), func(item int) int {
+				return item * 2
+			}).Collect()
+		}
+	})
+	b.Run("streamv3", func(b *testing.B) {
+		result := []int{}
+		for i := 0; i < b.N; i++ {
+			streamv3.OfSlice(data).Filter(func(item int) bool {
+				return item%2 == 0
+			}).Map(func(item int) int {
+				return item * 2
+			}).Scan(&result)
+		}
+	})
+}
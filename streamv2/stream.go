@@ -35,6 +35,9 @@ type Stream interface {
 	// 根据mapper func将stream中的elem对象转化成另一种对象
 	// mapper参数应为 func (item T) O，T为上游数据类型，O为产出的新数据类型
 	Map(mapper interface{}) Stream
+	// 根据mapper func将stream中的elem对象转化成另一种对象，并将[]O打平
+	// mapper参数应为 func (item T) []O，T为上游数据类型，O为产出的新数据类型
+	FlatMap(mapper interface{}) Stream
 	// 跳过前n条记录
 	Offset(n int) Stream
 	// 取前n条记录
@@ -85,6 +88,7 @@ type Streamer struct {
 	parallel     int
 	filterFunc   *reflect.Value
 	mapFunc      *reflect.Value
+	flatMapFunc  *reflect.Value
 	sortFunc     *reflect.Value
 	offset       int
 	limit        int
@@ -224,6 +228,50 @@ func (streamer *Streamer) Map(mapper interface{}) *Streamer {
 	}
 }
 
+// FlatMap 转化规则，mapper的参数elem是stream中的元素，mapper返回的slice会被打平后继续进入stream
+func (streamer *Streamer) FlatMap(mapper interface{}) *Streamer {
+	if streamer.err != nil {
+		return streamer
+	}
+	fv := reflect.ValueOf(mapper)
+	if fv.Kind() != reflect.Func {
+		streamer.err = fmt.Errorf("mapper must be a function, not %s", fv.Kind())
+		return streamer
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		streamer.err = fmt.Errorf("mapper's args number must equals 1, not %d", ft.NumIn())
+		return streamer
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		streamer.err = fmt.Errorf("upstream data's type is %s, but mapper's args type is %s", streamer.curType, ip1)
+		return streamer
+	}
+
+	if ft.NumOut() != 1 {
+		streamer.err = fmt.Errorf("mapper's output number must equals 1, not %d", ft.NumOut())
+		return streamer
+	}
+	op1 := ft.Out(0)
+	if op1.Kind() != reflect.Slice {
+		streamer.err = fmt.Errorf("mapper's return-val type should be slice, not %s", op1)
+		return streamer
+	}
+	return &Streamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		flatMapFunc:  &fv,
+		sortFunc:     nil,
+		offset:       streamer.offset,
+		limit:        streamer.limit,
+		curType:      op1.Elem(),
+	}
+}
+
 // Limit 取前n条记录，惰性操作，只在执行了终结操作时起作用
 func (streamer *Streamer) Limit(n int) *Streamer {
 	if streamer.err != nil {
@@ -548,6 +596,13 @@ func (streamer *Streamer) scan() ([]interface{}, error) {
 		if streamerList[i].filterFunc != nil {
 			newData = streamerList[i].filter(newData)
 		}
+		if streamerList[i].flatMapFunc != nil {
+			var err error
+			newData, err = streamerList[i].flatMap(newData)
+			if err != nil {
+				return nil, err
+			}
+		}
 		if streamerList[i].mapFunc != nil {
 			newData = streamerList[i]._map(newData)
 		}
@@ -647,6 +702,49 @@ func (streamer *Streamer) _map(data []interface{}) (result []interface{}) {
 	return result
 }
 
+// flatMap 内部实现，用于其他方法复用
+func (streamer *Streamer) flatMap(data []interface{}) (result []interface{}, err error) {
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	wg.Add(streamer.parallel)
+	batch := len(data) / streamer.parallel
+	results := make([][]interface{}, streamer.parallel, streamer.parallel)
+	for i := 0; i < streamer.parallel; i++ {
+		start := i * batch
+		end := start + batch
+		if i == streamer.parallel-1 && end < len(data) {
+			end = len(data)
+		}
+		go func(goroutineID, start, end int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						err = fmt.Errorf("panic: %v", r)
+					})
+				}
+				wg.Done()
+			}()
+			res := []interface{}{}
+			for i := start; i < end; i++ {
+				op := call(*streamer.flatMapFunc, data[i])
+				for j := 0; j < op[0].Len(); j++ {
+					res = append(res, op[0].Index(j).Interface())
+				}
+			}
+			results[goroutineID] = res
+		}(i, start, end)
+	}
+	wg.Wait()
+	// 内部多个goroutine并行，将内部panic作为error返回给终结操作
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(results); i++ {
+		result = append(result, results[i]...)
+	}
+	return result, nil
+}
+
 // groupBy GroupBy内部实现，支持并行
 func (streamer *Streamer) groupBy(keyer reflect.Value, scanResult []interface{}, valPointer *reflect.Value) error {
 	var wg sync.WaitGroup
@@ -654,8 +752,8 @@ func (streamer *Streamer) groupBy(keyer reflect.Value, scanResult []interface{},
 	val := *valPointer
 	batch := len(scanResult) / streamer.parallel
 	// collect results from different worker goroutine
-	// make the cap equals streamer.parallel, and use iteration index as goroutineID to avoid concurrent problem
-	resultCollection := make(map[int]map[interface{}][]interface{}, streamer.parallel)
+	// pre-allocate one slot per goroutine, and use iteration index as goroutineID to avoid concurrent map writes
+	resultCollection := make([]map[interface{}][]interface{}, streamer.parallel)
 
 	for i := 0; i < streamer.parallel; i++ {
 		start := i * batch
@@ -268,3 +268,36 @@ func TestStreamerCount(t *testing.T) {
 	}
 	assertEquals(t, len(testData), count)
 }
+
+func TestStreamerFlatMap(t *testing.T) {
+	result := []string{}
+	err := streamer.FlatMap(func(elem testUser) []string {
+		return strings.Split(elem.Email, "@")
+	}).Scan(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedResult := []string{
+		"zhangsan", "xxx.com", "lisi", "xxx.com", "wangwu", "xxx.com", "zhaoliu", "xxx.com",
+	}
+	assertEquals(t, result, expectedResult)
+
+	err = NewStreamerWithData(testData).FlatMap(func(elem testUser) string {
+		return elem.Email
+	}).Error()
+	if err == nil || !strings.Contains(err.Error(), "should be slice") {
+		t.Errorf("expected slice return-val error, but got %v", err)
+	}
+
+	result = []string{"stale"}
+	err = NewStreamerWithData(testData).Parallel(2).FlatMap(func(elem testUser) []string {
+		if elem.ID == 3 {
+			panic("bad user")
+		}
+		return []string{elem.Name}
+	}).Scan(&result)
+	if err == nil || !strings.Contains(err.Error(), "bad user") {
+		t.Errorf("expected panic in flatMapper to be reported, but got %v", err)
+	}
+	assertEquals(t, result, []string{"stale"})
+}
@@ -0,0 +1,108 @@
+package streamv3
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// FromCSV 读取CSV并将每一行转换为结构体T创建数据流，elemPtr为结构体的指针，用于确定T，如(*T)(nil)
+// 第一行为表头，按字段的csv tag（没有tag时使用字段名）匹配列，tag为"-"的字段和未导出的字段会被忽略
+// 支持string、bool、int、uint、float类型的字段；表头缺少字段对应的列、读取或解析失败时返回error
+func FromCSV(r io.Reader, elemPtr interface{}) (SliceStream, error) {
+	rt := reflect.TypeOf(elemPtr)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("elemPtr must be struct pointer, not %s", rt))
+	}
+	rt = rt.Elem()
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv header is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	// fields[i]为第i个字段对应的列，-1表示忽略该字段
+	fields := make([]int, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name := field.Tag.Get("csv")
+		if name == "-" || field.PkgPath != "" {
+			fields[i] = -1
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		column, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("csv column %s of field %s is missing", name, field.Name)
+		}
+		fields[i] = column
+	}
+
+	data := reflect.MakeSlice(reflect.SliceOf(rt), 0, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		elem := reflect.New(rt).Elem()
+		for i := 0; i < len(fields); i++ {
+			if fields[i] < 0 {
+				continue
+			}
+			if err := setCSVField(elem.Field(i), record[fields[i]]); err != nil {
+				return nil, fmt.Errorf("csv line %d, column %s: %s", line, header[fields[i]], err)
+			}
+		}
+		data = reflect.Append(data, elem)
+	}
+	return OfSlice(data.Interface()), nil
+}
+
+// setCSVField 将value解析为field的类型并赋值
+func setCSVField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
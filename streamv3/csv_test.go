@@ -0,0 +1,36 @@
+package streamv3
+
+import (
+	"strings"
+	"testing"
+)
+
+type csvUser struct {
+	ID     int    `csv:"id"`
+	Name   string `csv:"name"`
+	Score  float64
+	Active bool   `csv:"active"`
+	Note   string `csv:"-"`
+}
+
+func TestFromCSV(t *testing.T) {
+	s, err := FromCSV(strings.NewReader("name,id,Score,active,extra\nzhangsan,1,90.5,true,x\nlisi,2,60,false,y\n"), (*csvUser)(nil))
+	assertEquals(t, err, nil)
+	result := []csvUser{}
+	s.Scan(&result)
+	assertEquals(t, result, []csvUser{
+		{ID: 1, Name: "zhangsan", Score: 90.5, Active: true},
+		{ID: 2, Name: "lisi", Score: 60, Active: false},
+	})
+
+	_, err = FromCSV(strings.NewReader("name,id,active\nzhangsan,1,true\n"), (*csvUser)(nil))
+	assertEquals(t, err.Error(), "csv column Score of field Score is missing")
+
+	_, err = FromCSV(strings.NewReader("name,id,Score,active\nzhangsan,x,1,true\n"), (*csvUser)(nil))
+	if err == nil || !strings.HasPrefix(err.Error(), "csv line 2, column id:") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = FromCSV(strings.NewReader(""), (*csvUser)(nil))
+	assertEquals(t, err.Error(), "csv header is missing")
+}
@@ -0,0 +1,38 @@
+package streamv3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// OfJSONL 从r中读取NDJSON（每行一个json对象），每行反序列化成一个elemType类型的元素
+// 空行会被忽略，反序列化失败时返回的error中带有出错的行号
+func OfJSONL(r io.Reader, elemType reflect.Type) (SliceStream, error) {
+	if elemType == nil {
+		return nil, errors.New("elemType can't be nil")
+	}
+	reader := bufio.NewReader(r)
+	data := []interface{}{}
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			elem := reflect.New(elemType)
+			if unmarshalErr := json.Unmarshal(line, elem.Interface()); unmarshalErr != nil {
+				return nil, fmt.Errorf("line %d: %s", lineNum, unmarshalErr)
+			}
+			data = append(data, elem.Elem().Interface())
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return newSliceStreamer(data, elemType), nil
+}
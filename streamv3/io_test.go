@@ -0,0 +1,26 @@
+package streamv3
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOfJSONL(t *testing.T) {
+	lines := `{"ID":1,"Name":"zhangsan","Age":15,"Email":"zhangsan@xxx.com"}
+{"ID":2,"Name":"lisi","Age":15,"Email":"lisi@xxx.com"}
+
+{"ID":3,"Name":"wangwu","Age":20,"Email":"wangwu@xxx.com"}`
+	s, err := OfJSONL(strings.NewReader(lines), reflect.TypeOf(testUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := []testUser{}
+	s.Scan(&result)
+	assertEquals(t, result, testData[:3])
+
+	_, err = OfJSONL(strings.NewReader("{\"ID\":1}\n{bad json}\n"), reflect.TypeOf(testUser{}))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("expected error on line 2, but return %v", err)
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
 	"sync"
 )
 
 // MapStream MapStream
 type MapStream interface {
 	Parallel(parallel int) MapStream
+	// 跳过过滤后的前n个entry，entry的顺序参考OfMap
+	// 和SliceStream的Offset一样作用于整条链过滤后的最终结果，与调用的位置无关，多次调用时以最后一次为准
+	Offset(n int) MapStream
+	// 只保留过滤后的前n个entry，entry的顺序参考OfMap
+	// 和SliceStream的Limit一样作用于整条链过滤后的最终结果（先Offset再Limit），与调用的位置无关，多次调用时以最后一次为准
+	Limit(n int) MapStream
 	// 根据filter func过滤符合条件的elem
 	// filter参数应为 func (key K, val V) bool，K为map结构的key类型，V为map结构的value类型
 	Filter(filter ...interface{}) MapStream
@@ -23,6 +30,38 @@ type MapStream interface {
 	KeysToStream() SliceStream
 	// ValuesToStream 获取values SliceStream
 	ValuesToStream() SliceStream
+	// 根据keyer func从每个entry中提取key，并统计不同key的个数
+	// keyer参数应为 func (key K, val V) DK，DK必须是可比较的类型
+	CountDistinctValues(keyer interface{}) int
+	// 遍历过滤后的每个key，执行op
+	// op参数应为 func (key K)，K为map结构的key类型
+	ForeachKey(op interface{})
+	// 遍历过滤后的每个value，执行op
+	// op参数应为 func (val V)，V为map结构的value类型
+	ForeachValue(op interface{})
+	// 获取过滤后的entry个数
+	Count() int
+	// 根据accumulator将过滤后的value两两聚合，结果由result带出，和SliceStream的Reduce相同
+	// accumulator参数应为 func (val1, val2 V) V，V为map结构的value类型；result参数应为*V类型
+	Reduce(accumulator interface{}, result interface{})
+	// 交换过滤后的entry的key和value，写入result；多个entry的value相同时，后写入的覆盖先写入的（和ToMap相同），
+	// 由于map的遍历顺序不确定，最终保留哪一个key也不确定
+	// V必须是可比较的类型；result参数应为map[V]K或*map[V]K类型
+	Invert(result interface{})
+	// 将过滤后的entry写入result，不需要经过SliceStream重新计算key
+	// result参数应为map[K]V或*map[K]V类型
+	ToMap(result interface{})
+	// 根据keyer func对过滤后的entry分组，每个分组仍然是map
+	// keyer参数应为 func (key K, val V) GK，GK必须是可比较的类型；result参数应为map[GK]map[K]V或*map[GK]map[K]V类型
+	GroupBy(keyer interface{}, result interface{})
+	// 获取过滤后的entry组成的SliceStream，元素为Entry，Entry的Key和Value分别为K和V类型，顺序不确定
+	EntriesToStream() SliceStream
+	// 按key排序，产出以Entry为元素的SliceStream，Entry的Key和Value分别为K和V类型，key相等时的顺序不确定
+	// less参数应为 func (key1, key2 K) bool，K为map结构的key类型
+	SortedByKey(less interface{}) SliceStream
+	// 按value排序，产出以Entry为元素的SliceStream，value相等时的顺序不确定
+	// less参数应为 func (val1, val2 V) bool，V为map结构的value类型
+	SortedByValue(less interface{}) SliceStream
 }
 
 // MapStreamer MapStreamer
@@ -36,11 +75,15 @@ type MapStreamer struct {
 	mapFunc      *reflect.Value
 	flatMapFunc  *reflect.Value
 	pairData     []pair
+	offset       int
+	limit        int
 	curKeyType   reflect.Type
 	curValueType reflect.Type
 }
 
 // OfMap 只接受map类型
+// key为数值或string类型时，entry按key升序排列，从而使Offset/Limit以及产出的SliceStream的顺序是确定的；
+// 其他类型的key顺序不确定，需要确定的顺序时可以使用SortedByKey/SortedByValue
 func OfMap(data interface{}) MapStream {
 	val := reflect.ValueOf(data)
 	dt := reflect.TypeOf(data)
@@ -60,6 +103,11 @@ func OfMap(data interface{}) MapStream {
 			value: mapIter.Value().Interface(),
 		})
 	}
+	if keyType := val.Type().Key(); isOrdered(keyType) {
+		sort.Slice(pairData, func(i, j int) bool {
+			return lessOrdered(reflect.ValueOf(pairData[i].key), reflect.ValueOf(pairData[j].key))
+		})
+	}
 	s := &MapStreamer{
 		lastStreamer: nil,
 		parallel:     1,
@@ -73,6 +121,101 @@ func OfMap(data interface{}) MapStream {
 	return s
 }
 
+// OfKeyValues 将keys[i]和values[i]组成一个entry，keys和values都只接受slice类型
+// 长度不同时以较短的为准，keys中有重复的key时每个entry都会保留
+func OfKeyValues(keys, values interface{}) MapStream {
+	keyVal := reflect.ValueOf(keys)
+	if keyVal.Kind() != reflect.Slice {
+		panic(fmt.Errorf("keys must be slice, not %s", keyVal.Kind()))
+	}
+	valueVal := reflect.ValueOf(values)
+	if valueVal.Kind() != reflect.Slice {
+		panic(fmt.Errorf("values must be slice, not %s", valueVal.Kind()))
+	}
+	n := keyVal.Len()
+	if valueVal.Len() < n {
+		n = valueVal.Len()
+	}
+	pairData := make([]pair, 0, n)
+	for i := 0; i < n; i++ {
+		pairData = append(pairData, pair{
+			key:   keyVal.Index(i).Interface(),
+			value: valueVal.Index(i).Interface(),
+		})
+	}
+	return &MapStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		pairData:     pairData,
+		curKeyType:   keyVal.Type().Elem(),
+		curValueType: valueVal.Type().Elem(),
+	}
+}
+
+// MergeMap 合并a和b过滤后的entry，产出新的MapStream，a和b的key类型和value类型需要一致
+// key冲突时调用resolver，v1为a中（或之前合并得到）的value，v2为b中的value，返回值作为合并后的value
+// 合并在调用时立刻执行，新的MapStream并行度继承a
+// resolver参数应为 func (key K, v1, v2 V) V，K为map结构的key类型，V为map结构的value类型
+func MergeMap(a, b MapStream, resolver interface{}) MapStream {
+	aStreamer, ok := a.(*MapStreamer)
+	if !ok {
+		panic(fmt.Errorf("a must be *MapStreamer, not %s", reflect.TypeOf(a)))
+	}
+	bStreamer, ok := b.(*MapStreamer)
+	if !ok {
+		panic(fmt.Errorf("b must be *MapStreamer, not %s", reflect.TypeOf(b)))
+	}
+	if aStreamer.curKeyType != bStreamer.curKeyType {
+		panic(fmt.Errorf("a's key type is %s, but b's key type is %s", aStreamer.curKeyType, bStreamer.curKeyType))
+	}
+	if aStreamer.curValueType != bStreamer.curValueType {
+		panic(fmt.Errorf("a's value type is %s, but b's value type is %s", aStreamer.curValueType, bStreamer.curValueType))
+	}
+	fv := reflect.ValueOf(resolver)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("resolver must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 3 {
+		panic(fmt.Errorf("resolver's args number must equals 3, not %d", ft.NumIn()))
+	}
+	if ft.In(0) != aStreamer.curKeyType {
+		panic(fmt.Errorf("key's type is %s, but resolver's key type is %s", aStreamer.curKeyType, ft.In(0)))
+	}
+	if ft.In(1) != aStreamer.curValueType || ft.In(2) != aStreamer.curValueType {
+		panic(fmt.Errorf("value's type is %s, but resolver's value type is %s and %s", aStreamer.curValueType, ft.In(1), ft.In(2)))
+	}
+	if ft.NumOut() != 1 || ft.Out(0) != aStreamer.curValueType {
+		panic(fmt.Errorf("resolver's return-val type must be %s", aStreamer.curValueType))
+	}
+
+	pairData := []pair{}
+	index := map[interface{}]int{}
+	merge := func(data []pair) {
+		for i := 0; i < len(data); i++ {
+			if j, ok := index[data[i].key]; ok {
+				pairData[j].value = call(fv, data[i].key, pairData[j].value, data[i].value)[0].Interface()
+				continue
+			}
+			index[data[i].key] = len(pairData)
+			pairData = append(pairData, data[i])
+		}
+	}
+	merge(aStreamer.scanPairs())
+	merge(bStreamer.scanPairs())
+	return &MapStreamer{
+		lastStreamer: nil,
+		parallel:     aStreamer.parallel,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		pairData:     pairData,
+		curKeyType:   aStreamer.curKeyType,
+		curValueType: aStreamer.curValueType,
+	}
+}
+
 // Parallel 设置并行度
 func (streamer *MapStreamer) Parallel(parallel int) MapStream {
 	// at least 1 parallel
@@ -83,8 +226,39 @@ func (streamer *MapStreamer) Parallel(parallel int) MapStream {
 	if parallel > runtime.NumCPU()*2 {
 		parallel = runtime.NumCPU() * 2
 	}
-	streamer.parallel = parallel
-	return streamer
+	cloned := *streamer
+	cloned.parallel = parallel
+	return &cloned
+}
+
+// Offset 跳过前n个entry
+func (streamer *MapStreamer) Offset(n int) MapStream {
+	if n <= 0 {
+		panic(fmt.Errorf("offset rows can't less than or equal 0, but your args is %d", n))
+	}
+	return &MapStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		offset:       n,
+		limit:        streamer.limit,
+		curKeyType:   streamer.curKeyType,
+		curValueType: streamer.curValueType,
+	}
+}
+
+// Limit 只保留前n个entry
+func (streamer *MapStreamer) Limit(n int) MapStream {
+	if n <= 0 {
+		panic(fmt.Errorf("limit rows can't less than or equal 0, but your args is %d", n))
+	}
+	return &MapStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		offset:       streamer.offset,
+		limit:        n,
+		curKeyType:   streamer.curKeyType,
+		curValueType: streamer.curValueType,
+	}
 }
 
 // Filter 过滤规则，filter的参数elem是stream中的元素
@@ -126,6 +300,8 @@ func (streamer *MapStreamer) Filter(filters ...interface{}) MapStream {
 		parallel:     streamer.parallel,
 		filterFunc:   fvs,
 		mapFunc:      nil,
+		offset:       streamer.offset,
+		limit:        streamer.limit,
 		curKeyType:   streamer.curKeyType,
 		curValueType: streamer.curValueType,
 	}
@@ -162,6 +338,8 @@ func (streamer *MapStreamer) Map(mapper interface{}) SliceStream {
 		filterFunc:   nil,
 		mapFunc:      &fv,
 		flatMapFunc:  nil,
+		offset:       streamer.offset,
+		limit:        streamer.limit,
 		curKeyType:   streamer.curKeyType,
 		curValueType: streamer.curValueType,
 	}
@@ -217,6 +395,8 @@ func (streamer *MapStreamer) FlatMap(flatMapper interface{}) SliceStream {
 		filterFunc:   nil,
 		mapFunc:      nil,
 		flatMapFunc:  &fv,
+		offset:       streamer.offset,
+		limit:        streamer.limit,
 		curKeyType:   streamer.curKeyType,
 		curValueType: streamer.curValueType,
 	}
@@ -238,18 +418,7 @@ func (streamer *MapStreamer) FlatMap(flatMapper interface{}) SliceStream {
 
 // KeysToStream 获取key的SliceStreamer
 func (streamer *MapStreamer) KeysToStream() SliceStream {
-	streamerList := []*MapStreamer{}
-	lastStreamer := streamer
-	for ; lastStreamer != nil; lastStreamer = lastStreamer.lastStreamer {
-		streamerList = append(streamerList, lastStreamer)
-	}
-	newData := make([]pair, 0, len(streamerList[len(streamerList)-1].pairData))
-	newData = append(newData, streamerList[len(streamerList)-1].pairData...)
-	for i := len(streamerList) - 1; i >= 0; i-- {
-		if streamerList[i].filterFunc != nil {
-			newData = streamerList[i].filter(newData)
-		}
-	}
+	newData := streamer.scanPairs()
 	data := []interface{}{}
 	for i := 0; i < len(newData); i++ {
 		data = append(data, newData[i].key)
@@ -272,18 +441,7 @@ func (streamer *MapStreamer) KeysToStream() SliceStream {
 
 // ValuesToStream 获取value的SliceStreamer
 func (streamer *MapStreamer) ValuesToStream() SliceStream {
-	streamerList := []*MapStreamer{}
-	lastStreamer := streamer
-	for ; lastStreamer != nil; lastStreamer = lastStreamer.lastStreamer {
-		streamerList = append(streamerList, lastStreamer)
-	}
-	newData := make([]pair, 0, len(streamerList[len(streamerList)-1].pairData))
-	newData = append(newData, streamerList[len(streamerList)-1].pairData...)
-	for i := len(streamerList) - 1; i >= 0; i-- {
-		if streamerList[i].filterFunc != nil {
-			newData = streamerList[i].filter(newData)
-		}
-	}
+	newData := streamer.scanPairs()
 	data := []interface{}{}
 	for i := 0; i < len(newData); i++ {
 		data = append(data, newData[i].value)
@@ -304,6 +462,155 @@ func (streamer *MapStreamer) ValuesToStream() SliceStream {
 	}
 }
 
+// Count 获取过滤后的entry个数，只执行过滤链，不会构造key或value的SliceStreamer
+func (streamer *MapStreamer) Count() int {
+	return len(streamer.scanPairs())
+}
+
+// Reduce 聚合过滤后的value
+func (streamer *MapStreamer) Reduce(accumulator interface{}, result interface{}) {
+	streamer.ValuesToStream().Reduce(accumulator, result)
+}
+
+// Invert 交换key和value
+func (streamer *MapStreamer) Invert(result interface{}) {
+	if !streamer.curValueType.Comparable() {
+		panic(fmt.Errorf("value's type should be comparable to be used as key, but %s is not", streamer.curValueType))
+	}
+	val := checkResultMap("Invert", result, streamer.curValueType, streamer.curKeyType)
+	newData := streamer.scanPairs()
+	for i := 0; i < len(newData); i++ {
+		val.SetMapIndex(valueOf(newData[i].value, streamer.curValueType), valueOf(newData[i].key, streamer.curKeyType))
+	}
+}
+
+// ToMap 将过滤后的entry带出
+func (streamer *MapStreamer) ToMap(result interface{}) {
+	val := checkResultMap("ToMap", result, streamer.curKeyType, streamer.curValueType)
+	newData := streamer.scanPairs()
+	for i := 0; i < len(newData); i++ {
+		val.SetMapIndex(valueOf(newData[i].key, streamer.curKeyType), valueOf(newData[i].value, streamer.curValueType))
+	}
+}
+
+// GroupBy 对过滤后的entry分组
+func (streamer *MapStreamer) GroupBy(keyer interface{}, result interface{}) {
+	fv := reflect.ValueOf(keyer)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("keyer must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("keyer's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if ft.In(0) != streamer.curKeyType {
+		panic(fmt.Errorf("key's type is %s, but keyer's key type is %s", streamer.curKeyType, ft.In(0)))
+	}
+	if ft.In(1) != streamer.curValueType {
+		panic(fmt.Errorf("value's type is %s, but keyer's value type is %s", streamer.curValueType, ft.In(1)))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("keyer's output number must equals 1, not %d", ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if !op1.Comparable() {
+		panic(fmt.Errorf("keyer's return-val type should be comparable, but %s is not", op1))
+	}
+	groupType := reflect.MapOf(streamer.curKeyType, streamer.curValueType)
+	val := checkResultMap("GroupBy", result, op1, groupType)
+
+	newData := streamer.scanPairs()
+	for i := 0; i < len(newData); i++ {
+		groupKey := call(fv, newData[i].key, newData[i].value)[0]
+		group := val.MapIndex(groupKey)
+		if !group.IsValid() {
+			group = reflect.MakeMap(groupType)
+			val.SetMapIndex(groupKey, group)
+		}
+		group.SetMapIndex(valueOf(newData[i].key, streamer.curKeyType), valueOf(newData[i].value, streamer.curValueType))
+	}
+}
+
+// EntriesToStream 获取entry的SliceStreamer
+func (streamer *MapStreamer) EntriesToStream() SliceStream {
+	return entriesToStream(streamer.scanPairs(), streamer.parallel)
+}
+
+// CountDistinctValues 统计经过过滤后，keyer提取出的不同key的个数
+func (streamer *MapStreamer) CountDistinctValues(keyer interface{}) int {
+	fv := reflect.ValueOf(keyer)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("keyer must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("keyer's args number must equals 2, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curKeyType != ip1 {
+		panic(fmt.Errorf("key's type is %s, but keyer's key type is %s", streamer.curKeyType, ip1))
+	}
+	ip2 := ft.In(1)
+	if streamer.curValueType != ip2 {
+		panic(fmt.Errorf("value's type is %s, but keyer's value type is %s", streamer.curValueType, ip2))
+	}
+
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("keyer's output number must equals 1, not %d", ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if !op1.Comparable() {
+		panic(fmt.Errorf("keyer's return-val type should be comparable, but %s is not", op1))
+	}
+
+	newData := streamer.scanPairs()
+	distinct := make(map[interface{}]struct{}, len(newData))
+	for i := 0; i < len(newData); i++ {
+		op := call(fv, newData[i].key, newData[i].value)
+		distinct[op[0].Interface()] = struct{}{}
+	}
+	return len(distinct)
+}
+
+// ForeachKey 遍历过滤后的key
+func (streamer *MapStreamer) ForeachKey(op interface{}) {
+	fv := checkForeachOp(op, streamer.curKeyType, "key")
+	newData := streamer.scanPairs()
+	for i := 0; i < len(newData); i++ {
+		_ = call(fv, newData[i].key)
+	}
+}
+
+// ForeachValue 遍历过滤后的value
+func (streamer *MapStreamer) ForeachValue(op interface{}) {
+	fv := checkForeachOp(op, streamer.curValueType, "value")
+	newData := streamer.scanPairs()
+	for i := 0; i < len(newData); i++ {
+		_ = call(fv, newData[i].value)
+	}
+}
+
+// SortedByKey 按key排序，产出Entry
+func (streamer *MapStreamer) SortedByKey(less interface{}) SliceStream {
+	fv := checkLess(less, streamer.curKeyType, "key")
+	newData := streamer.scanPairs()
+	sort.SliceStable(newData, func(i, j int) bool {
+		return call(fv, newData[i].key, newData[j].key)[0].Bool()
+	})
+	return entriesToStream(newData, streamer.parallel)
+}
+
+// SortedByValue 按value排序，产出Entry
+func (streamer *MapStreamer) SortedByValue(less interface{}) SliceStream {
+	fv := checkLess(less, streamer.curValueType, "value")
+	newData := streamer.scanPairs()
+	sort.SliceStable(newData, func(i, j int) bool {
+		return call(fv, newData[i].value, newData[j].value)[0].Bool()
+	})
+	return entriesToStream(newData, streamer.parallel)
+}
+
 /*
  * ============================================
  * 				inner implement
@@ -328,22 +635,55 @@ func (streamer *MapStreamer) scan() []interface{} {
 		if streamerList[i].filterFunc != nil {
 			newData = streamerList[i].filter(newData)
 		}
+		// map/flatMap节点一定是链的末尾，在转换之前按offset和limit截取
 		if streamerList[i].flatMapFunc != nil {
-			return streamerList[i].flatMap(newData)
+			return streamerList[i].flatMap(streamerList[i].page(newData))
 		}
 		if streamerList[i].mapFunc != nil {
-			return streamerList[i]._map(newData)
+			return streamerList[i]._map(streamerList[i].page(newData))
 		}
 	}
 	return []interface{}{}
 }
 
+// scanPairs 只执行过滤链，返回过滤后的entry
+func (streamer *MapStreamer) scanPairs() []pair {
+	streamerList := []*MapStreamer{}
+	lastStreamer := streamer
+	for ; lastStreamer != nil; lastStreamer = lastStreamer.lastStreamer {
+		streamerList = append(streamerList, lastStreamer)
+	}
+	newData := make([]pair, 0, len(streamerList[len(streamerList)-1].pairData))
+	newData = append(newData, streamerList[len(streamerList)-1].pairData...)
+	for i := len(streamerList) - 1; i >= 0; i-- {
+		if streamerList[i].filterFunc != nil {
+			newData = streamerList[i].filter(newData)
+		}
+	}
+	return streamer.page(newData)
+}
+
+// page 按offset和limit截取entry，offset和limit会被传递给之后的节点，只需要在链的末尾截取一次
+func (streamer *MapStreamer) page(data []pair) []pair {
+	if streamer.offset > 0 {
+		if streamer.offset > len(data) {
+			return data[:0]
+		}
+		data = data[streamer.offset:]
+	}
+	if streamer.limit > 0 && streamer.limit < len(data) {
+		data = data[:streamer.limit]
+	}
+	return data
+}
+
 // filter 内部实现，用于其他方法复用
 func (streamer *MapStreamer) filter(data []pair) (result []pair) {
 	if len(streamer.filterFunc) == 0 {
 		return data
 	}
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
 	wg.Add(streamer.parallel)
 	batch := len(data) / streamer.parallel
@@ -357,7 +697,9 @@ func (streamer *MapStreamer) filter(data []pair) (result []pair) {
 		go func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
@@ -395,6 +737,7 @@ func (streamer *MapStreamer) _map(data []pair) (result []interface{}) {
 		return []interface{}{}
 	}
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
 	wg.Add(streamer.parallel)
 	batch := len(data) / streamer.parallel
@@ -408,7 +751,9 @@ func (streamer *MapStreamer) _map(data []pair) (result []interface{}) {
 		go func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
@@ -437,6 +782,7 @@ func (streamer *MapStreamer) flatMap(data []pair) (result []interface{}) {
 		return []interface{}{}
 	}
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
 	wg.Add(streamer.parallel)
 	batch := len(data) / streamer.parallel
@@ -450,7 +796,9 @@ func (streamer *MapStreamer) flatMap(data []pair) (result []interface{}) {
 		go func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
@@ -474,3 +822,87 @@ func (streamer *MapStreamer) flatMap(data []pair) (result []interface{}) {
 	}
 	return result
 }
+
+// checkForeachOp 校验op为 func (item T)，T为argType，name用于错误信息
+func checkForeachOp(op interface{}, argType reflect.Type, name string) reflect.Value {
+	fv := reflect.ValueOf(op)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("op must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("op's args number must equals 1, not %d", ft.NumIn()))
+	}
+	if argType != ft.In(0) {
+		panic(fmt.Errorf("%s's type is %s, but op's args type is %s", name, argType, ft.In(0)))
+	}
+	if ft.NumOut() != 0 {
+		panic(fmt.Errorf("op's output number must equals 0, not %d", ft.NumOut()))
+	}
+	return fv
+}
+
+// checkLess 校验less的签名为 func (a, b T) bool，T为argType
+func checkLess(less interface{}, argType reflect.Type, name string) reflect.Value {
+	fv := reflect.ValueOf(less)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("less must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("less's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if ft.In(0) != argType || ft.In(1) != argType {
+		panic(fmt.Errorf("%s's type is %s, but less's args type is %s and %s", name, argType, ft.In(0), ft.In(1)))
+	}
+	if ft.NumOut() != 1 || ft.Out(0).Kind() != reflect.Bool {
+		panic(fmt.Errorf("less's return-val type must be bool"))
+	}
+	return fv
+}
+
+// entriesToStream 将entry按顺序转换为以Entry为元素的SliceStreamer
+func entriesToStream(pairs []pair, parallel int) SliceStream {
+	data := make([]interface{}, len(pairs))
+	for i := 0; i < len(pairs); i++ {
+		data[i] = Entry{
+			Key:   pairs[i].key,
+			Value: pairs[i].value,
+		}
+	}
+	return &SliceStreamer{
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		lastStreamer: nil,
+		dataGetter: &sliceGetter{
+			data: data,
+		},
+		parallel:   parallel,
+		filterFunc: nil,
+		mapFunc:    nil,
+		curType:    entryType,
+	}
+}
+
+// checkResultMap 校验result为map[K]V或*map[K]V，返回可写入的map，result为nil map的指针时会初始化
+func checkResultMap(name string, result interface{}, keyType, valueType reflect.Type) reflect.Value {
+	val := reflect.ValueOf(result)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Map {
+		panic(fmt.Errorf("%s result must be map or map pointer, not %s", name, val.Kind()))
+	}
+	if val.Type().Key() != keyType {
+		panic(fmt.Errorf("%s result's key type should be %s, not %s", name, keyType, val.Type().Key()))
+	}
+	if val.Type().Elem() != valueType {
+		panic(fmt.Errorf("%s result's value type should be %s, not %s", name, valueType, val.Type().Elem()))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+	return val
+}
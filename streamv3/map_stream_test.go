@@ -1,6 +1,7 @@
 package streamv3
 
 import (
+	"sort"
 	"strings"
 	"testing"
 )
@@ -129,3 +130,245 @@ func TestMapStreamerValuesToStream(t *testing.T) {
 	}
 	assertEquals(t, result, expectedResult)
 }
+
+func TestMapStreamerCountDistinctValues(t *testing.T) {
+	count := mapStreamer.CountDistinctValues(func(key int64, val testUser) int {
+		return val.Age
+	})
+	assertEquals(t, count, 3)
+
+	count = mapStreamer.Filter(func(key int64, val testUser) bool {
+		return val.Age > 15
+	}).CountDistinctValues(func(key int64, val testUser) int {
+		return val.Age
+	})
+	assertEquals(t, count, 2)
+}
+
+func TestMapStreamerForeachKey(t *testing.T) {
+	var sum int64
+	mapStreamer.ForeachKey(func(key int64) {
+		sum += key
+	})
+	assertEquals(t, sum, int64(10))
+}
+
+func TestMapStreamerForeachValue(t *testing.T) {
+	names := []string{}
+	mapStreamer.Filter(func(key int64, val testUser) bool {
+		return val.Age > 15
+	}).ForeachValue(func(val testUser) {
+		names = append(names, val.Name)
+	})
+	sort.Strings(names)
+	assertEquals(t, names, []string{"wangwu", "zhaoliu"})
+}
+
+func TestOfKeyValues(t *testing.T) {
+	ids := []int64{10, 20, 30, 40, 50}
+	result := map[int64]testUser{}
+	OfKeyValues(ids, testData).Filter(func(key int64, val testUser) bool {
+		return val.Age > 15
+	}).Map(func(key int64, val testUser) testUser {
+		val.ID = int(key)
+		return val
+	}).ToMap(func(elem testUser) int64 {
+		return int64(elem.ID)
+	}, &result)
+	expectedResult := map[int64]testUser{
+		30: {ID: 30, Name: "wangwu", Age: 20, Email: "wangwu@xxx.com"},
+		40: {ID: 40, Name: "zhaoliu", Age: 25, Email: "zhaoliu@xxx.com"},
+	}
+	assertEquals(t, result, expectedResult)
+}
+
+func TestMapStreamerSortedByKey(t *testing.T) {
+	result := []Entry{}
+	mapStreamer.Filter(func(key int64, val testUser) bool {
+		return key > 1
+	}).SortedByKey(func(key1, key2 int64) bool {
+		return key1 > key2
+	}).Scan(&result)
+	assertEquals(t, result, []Entry{
+		{Key: int64(4), Value: testDataMap[4]},
+		{Key: int64(3), Value: testDataMap[3]},
+		{Key: int64(2), Value: testDataMap[2]},
+	})
+
+	names := []string{}
+	mapStreamer.SortedByKey(func(key1, key2 int64) bool {
+		return key1 < key2
+	}).Limit(2).Map(func(entry Entry) string {
+		return entry.Value.(testUser).Name
+	}).Scan(&names)
+	assertEquals(t, names, []string{"zhangsan", "lisi"})
+}
+
+func TestMapStreamerSortedByValue(t *testing.T) {
+	keys := []int64{}
+	OfMap(map[int64]string{1: "c", 2: "a", 3: "b"}).SortedByValue(func(val1, val2 string) bool {
+		return val1 < val2
+	}).Map(func(entry Entry) int64 {
+		return entry.Key.(int64)
+	}).Scan(&keys)
+	assertEquals(t, keys, []int64{2, 3, 1})
+}
+
+func TestMapStreamerEntriesToStream(t *testing.T) {
+	result := []Entry{}
+	mapStreamer.Filter(func(key int64, val testUser) bool {
+		return val.Age > 15
+	}).EntriesToStream().Sorted(func(e1, e2 Entry) bool {
+		return e1.Key.(int64) < e2.Key.(int64)
+	}).Scan(&result)
+	assertEquals(t, result, []Entry{
+		{Key: int64(3), Value: testDataMap[3]},
+		{Key: int64(4), Value: testDataMap[4]},
+	})
+	assertEquals(t, mapStreamer.EntriesToStream().Count(), 4)
+}
+
+func TestMapStreamerCount(t *testing.T) {
+	assertEquals(t, mapStreamer.Count(), 4)
+	assertEquals(t, mapStreamer.Filter(func(key int64, val testUser) bool {
+		return val.Age == 15
+	}).Count(), 2)
+}
+
+func TestMapStreamerReduce(t *testing.T) {
+	total := 0
+	OfMap(map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}).Filter(func(key string, val int) bool {
+		return key != "d"
+	}).Reduce(func(val1, val2 int) int {
+		return val1 + val2
+	}, &total)
+	assertEquals(t, total, 6)
+}
+
+func TestMapStreamerInvert(t *testing.T) {
+	var result map[string]int
+	OfMap(map[int]string{1: "a", 2: "b", 3: "c"}).Filter(func(key int, val string) bool {
+		return key < 3
+	}).Invert(&result)
+	assertEquals(t, result, map[string]int{"a": 1, "b": 2})
+
+	collapsed := map[string]int{}
+	OfMap(map[int]string{1: "a", 2: "a"}).Invert(collapsed)
+	assertEquals(t, len(collapsed), 1)
+
+	// value为nil的entry不会被丢弃
+	inverted := map[interface{}]string{}
+	OfMap(map[string]interface{}{"a": nil, "b": 1}).Invert(&inverted)
+	assertEquals(t, inverted, map[interface{}]string{nil: "a", 1: "b"})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for incomparable value")
+		}
+	}()
+	OfMap(map[int][]string{1: {"a"}}).Invert(&map[string]int{})
+}
+
+func TestMergeMap(t *testing.T) {
+	defaults := OfMap(map[string]int{"timeout": 10, "retry": 3, "debug": 0})
+	overrides := OfMap(map[string]int{"timeout": 30, "workers": 8, "debug": -1}).Filter(func(key string, val int) bool {
+		return val >= 0
+	})
+	result := map[int]string{}
+	MergeMap(defaults, overrides, func(key string, v1, v2 int) int {
+		return v1 + v2
+	}).Invert(&result)
+	assertEquals(t, result, map[int]string{40: "timeout", 3: "retry", 0: "debug", 8: "workers"})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for mismatched value type")
+		}
+	}()
+	MergeMap(defaults, OfMap(map[string]string{}), func(key string, v1, v2 int) int {
+		return v1
+	})
+}
+
+func TestMapStreamerToMap(t *testing.T) {
+	var result map[int64]testUser
+	mapStreamer.Filter(func(key int64, val testUser) bool {
+		return val.Age > 15
+	}).ToMap(&result)
+	assertEquals(t, result, map[int64]testUser{3: testDataMap[3], 4: testDataMap[4]})
+
+	// value为nil的entry不会被丢弃
+	withNil := map[string]interface{}{}
+	OfMap(map[string]interface{}{"a": nil, "b": 1}).ToMap(&withNil)
+	assertEquals(t, withNil, map[string]interface{}{"a": nil, "b": 1})
+}
+
+func TestMapStreamerGroupBy(t *testing.T) {
+	result := map[int]map[int64]testUser{}
+	mapStreamer.Filter(func(key int64, val testUser) bool {
+		return key != 4
+	}).GroupBy(func(key int64, val testUser) int {
+		return val.Age
+	}, &result)
+	assertEquals(t, result, map[int]map[int64]testUser{
+		15: {1: testDataMap[1], 2: testDataMap[2]},
+		20: {3: testDataMap[3]},
+	})
+
+	// value为nil的entry不会被丢弃
+	groups := map[bool]map[string]interface{}{}
+	OfMap(map[string]interface{}{"a": nil, "b": 1, "c": nil}).GroupBy(func(key string, val interface{}) bool {
+		return val == nil
+	}, &groups)
+	assertEquals(t, groups, map[bool]map[string]interface{}{true: {"a": nil, "c": nil}, false: {"b": 1}})
+}
+
+func TestMapStreamerOffsetLimit(t *testing.T) {
+	keys := []int64{}
+	mapStreamer.Offset(1).Limit(2).KeysToStream().Scan(&keys)
+	assertEquals(t, keys, []int64{2, 3})
+
+	names := []string{}
+	mapStreamer.Filter(func(key int64, val testUser) bool {
+		return key != 2
+	}).Limit(2).Map(func(key int64, val testUser) string {
+		return val.Name
+	}).Scan(&names)
+	assertEquals(t, names, []string{"zhangsan", "wangwu"})
+
+	assertEquals(t, mapStreamer.Offset(10).Count(), 0)
+	assertEquals(t, OfMap(map[string]int{"b": 2, "a": 1, "c": 3}).Limit(2).Count(), 2)
+	values := []int{}
+	OfMap(map[string]int{"b": 2, "a": 1, "c": 3}).Offset(1).ValuesToStream().Scan(&values)
+	assertEquals(t, values, []int{2, 3})
+
+	// 和SliceStream相同，放在Filter之前的Offset/Limit也作用于过滤后的结果
+	keys = []int64{}
+	mapStreamer.Offset(1).Limit(1).Filter(func(key int64, val testUser) bool {
+		return key != 2
+	}).KeysToStream().Scan(&keys)
+	assertEquals(t, keys, []int64{3})
+	names = []string{}
+	mapStreamer.Limit(2).Filter(func(key int64, val testUser) bool {
+		return key != 1
+	}).Map(func(key int64, val testUser) string {
+		return val.Name
+	}).Scan(&names)
+	assertEquals(t, names, []string{"lisi", "wangwu"})
+	sliceNames := []string{}
+	OfSlice(testData).Limit(2).Filter(func(elem testUser) bool {
+		return elem.ID != 1
+	}).Map(func(elem testUser) string {
+		return elem.Name
+	}).Scan(&sliceNames)
+	assertEquals(t, names, sliceNames)
+
+	for _, page := range []func(){
+		func() { mapStreamer.Offset(0) },
+		func() { mapStreamer.Limit(-1) },
+	} {
+		if Try(page) == nil {
+			t.Error("expected panic for non-positive offset or limit")
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package streamv3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// 执行计划中引用的函数和数据源都需要先注册，序列化时只保存名称，
+// 反序列化的进程通过相同的名称注册本地的函数和数据源，从而重建相同的stream链
+var planRegistry = struct {
+	sync.RWMutex
+	funcs   map[string]reflect.Value
+	names   map[uintptr]string
+	sources map[string]interface{}
+}{
+	funcs:   map[string]reflect.Value{},
+	names:   map[uintptr]string{},
+	sources: map[string]interface{}{},
+}
+
+// RegisterFunc 以name注册一个可以出现在执行计划中的函数（filter/mapper/sorter等）
+// 序列化时通过函数地址查找名称，同一个函数字面量产生的不同闭包地址相同，无法区分，应只注册其中一个
+func RegisterFunc(name string, fn interface{}) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("fn must be a function, not %s", fv.Kind()))
+	}
+	planRegistry.Lock()
+	defer planRegistry.Unlock()
+	if registered, ok := planRegistry.names[fv.Pointer()]; ok && registered != name {
+		panic(fmt.Errorf("fn has been registered as %s", registered))
+	}
+	planRegistry.funcs[name] = fv
+	planRegistry.names[fv.Pointer()] = name
+}
+
+// RegisterSource 以name注册一个数据源，data只接受slice类型
+func RegisterSource(name string, data interface{}) {
+	val := reflect.ValueOf(data)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Slice {
+		panic(fmt.Errorf("source must be slice, not %s", val.Kind()))
+	}
+	planRegistry.Lock()
+	defer planRegistry.Unlock()
+	planRegistry.sources[name] = data
+}
+
+// OfSource 从已注册的数据源创建stream，只有这样创建的stream才能MarshalPlan
+func OfSource(name string) SliceStream {
+	planRegistry.RLock()
+	data, ok := planRegistry.sources[name]
+	planRegistry.RUnlock()
+	if !ok {
+		panic(fmt.Errorf("source %s is not registered", name))
+	}
+	s := OfSlice(data).(*SliceStreamer)
+	s.dataGetter.(*sliceGetter).source = name
+	return s
+}
+
+// planNode 执行计划中的一个节点，对应stream链上的一个SliceStreamer
+type planNode struct {
+	Op           string   `json:"op"`
+	Source       string   `json:"source,omitempty"`
+	Funcs        []string `json:"funcs,omitempty"`
+	Size         int      `json:"size,omitempty"`
+	Step         int      `json:"step,omitempty"`
+	Parallel     int      `json:"parallel"`
+	WorkStealing bool     `json:"workStealing,omitempty"`
+	Offset       int      `json:"offset,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	SkipLast     int      `json:"skipLast,omitempty"`
+}
+
+// MarshalPlan 将stream链序列化成执行计划
+func (streamer *SliceStreamer) MarshalPlan() ([]byte, error) {
+	streamerList := streamer.chain()
+	nodes := make([]planNode, 0, len(streamerList))
+	for i := len(streamerList) - 1; i >= 0; i-- {
+		node, err := streamerList[i].planNode()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return json.Marshal(nodes)
+}
+
+// planNode 将当前节点转换成planNode，节点上的函数必须已经注册
+func (streamer *SliceStreamer) planNode() (planNode, error) {
+	node := planNode{
+		Op:           "pass",
+		Parallel:     streamer.parallel,
+		WorkStealing: streamer.workStealing,
+		Offset:       streamer.offset,
+		Limit:        streamer.limit,
+		SkipLast:     streamer.skipLast,
+	}
+	var funcs []reflect.Value
+	switch {
+	case streamer.lastStreamer == nil:
+		getter, ok := streamer.dataGetter.(*sliceGetter)
+		if !ok || getter.source == "" {
+			return node, errors.New("the source of stream must be created by OfSource")
+		}
+		node.Op = "source"
+		node.Source = getter.source
+	case len(streamer.filterFunc) > 0:
+		node.Op = "filter"
+		funcs = streamer.filterFunc
+	case streamer.indexFilter != nil:
+		node.Op = "filterIndexed"
+		funcs = []reflect.Value{*streamer.indexFilter}
+	case streamer.mapFunc != nil:
+		node.Op = "map"
+		funcs = []reflect.Value{*streamer.mapFunc}
+	case streamer.flatMapFunc != nil:
+		node.Op = "flatMap"
+		funcs = []reflect.Value{*streamer.flatMapFunc}
+	case streamer.emitFunc != nil:
+		node.Op = "mapEmit"
+		funcs = []reflect.Value{*streamer.emitFunc}
+	case streamer.mapAllFunc != nil:
+		node.Op = "mapAll"
+		funcs = []reflect.Value{*streamer.mapAllFunc}
+	case streamer.indexMapper != nil:
+		node.Op = "mapIndexed"
+		funcs = []reflect.Value{*streamer.indexMapper}
+	case streamer.sortFunc != nil:
+		node.Op = "sorted"
+		funcs = []reflect.Value{*streamer.sortFunc}
+	case streamer.peekFunc != nil:
+		node.Op = "peek"
+		funcs = []reflect.Value{*streamer.peekFunc}
+	case streamer.untilFunc != nil:
+		node.Op = "takeUntil"
+		funcs = []reflect.Value{*streamer.untilFunc}
+	case streamer.groupFunc != nil:
+		node.Op = "groupByStream"
+		funcs = []reflect.Value{*streamer.groupFunc}
+	case streamer.distinct:
+		node.Op = "distinct"
+	case streamer.reverse:
+		node.Op = "reverse"
+	case streamer.windowSize > 0 && streamer.keepTail:
+		node.Op = "chunk"
+		node.Size = streamer.windowSize
+	case streamer.windowSize > 0:
+		node.Op = "window"
+		node.Size = streamer.windowSize
+		node.Step = streamer.windowStep
+	}
+	planRegistry.RLock()
+	defer planRegistry.RUnlock()
+	for _, fv := range funcs {
+		name, ok := planRegistry.names[fv.Pointer()]
+		if !ok {
+			return node, fmt.Errorf("%s's func %s is not registered", node.Op, fv.Type())
+		}
+		node.Funcs = append(node.Funcs, name)
+	}
+	return node, nil
+}
+
+// UnmarshalPlan 根据MarshalPlan产出的执行计划重建stream链，计划中引用的函数和数据源必须已经注册
+func UnmarshalPlan(plan []byte) (stream SliceStream, err error) {
+	nodes := []planNode{}
+	if err := json.Unmarshal(plan, &nodes); err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 || nodes[0].Op != "source" {
+		return nil, errors.New("plan must start with a source")
+	}
+	// 函数签名不匹配等情况和直接调用一样会panic，这里转换成error返回
+	defer func() {
+		if r := recover(); r != nil {
+			stream, err = nil, fmt.Errorf("invalid plan: %v", r)
+		}
+	}()
+	var streamer *SliceStreamer
+	for i, node := range nodes {
+		funcs, err := lookupFuncs(node.Funcs)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && node.Op == "source" {
+			return nil, errors.New("plan can only have one source")
+		}
+		// 只接受一个函数的操作，函数数量不对时fn为nil，调用时panic
+		var fn interface{}
+		if len(funcs) == 1 {
+			fn = funcs[0]
+		}
+		var next SliceStream
+		switch node.Op {
+		case "source":
+			next = OfSource(node.Source)
+		case "pass":
+			next = &SliceStreamer{lastStreamer: streamer, curType: streamer.curType}
+		case "filter":
+			next = streamer.Filter(funcs...)
+		case "filterIndexed":
+			next = streamer.FilterIndexed(fn)
+		case "map":
+			next = streamer.Map(fn)
+		case "flatMap":
+			next = streamer.FlatMap(fn)
+		case "mapEmit":
+			next = streamer.MapEmit(fn)
+		case "mapAll":
+			next = streamer.MapAll(fn)
+		case "mapIndexed":
+			next = streamer.MapIndexed(fn)
+		case "sorted":
+			next = streamer.Sorted(fn)
+		case "peek":
+			next = streamer.Peek(fn)
+		case "takeUntil":
+			next = streamer.TakeUntil(fn)
+		case "groupByStream":
+			next = streamer.GroupByStream(fn)
+		case "distinct":
+			next = streamer.Distinct()
+		case "reverse":
+			next = streamer.Reverse()
+		case "chunk":
+			next = streamer.Chunk(node.Size)
+		case "window":
+			next = streamer.Window(node.Size, node.Step)
+		default:
+			return nil, fmt.Errorf("unknown op %s", node.Op)
+		}
+		streamer = next.(*SliceStreamer)
+		streamer.parallel = fixParallel(node.Parallel)
+		streamer.workStealing = node.WorkStealing
+		streamer.offset = node.Offset
+		streamer.limit = node.Limit
+		streamer.skipLast = node.SkipLast
+	}
+	return streamer, nil
+}
+
+// lookupFuncs 根据名称查找已注册的函数
+func lookupFuncs(names []string) ([]interface{}, error) {
+	planRegistry.RLock()
+	defer planRegistry.RUnlock()
+	funcs := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		fv, ok := planRegistry.funcs[name]
+		if !ok {
+			return nil, fmt.Errorf("func %s is not registered", name)
+		}
+		funcs = append(funcs, fv.Interface())
+	}
+	return funcs, nil
+}
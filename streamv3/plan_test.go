@@ -0,0 +1,51 @@
+package streamv3
+
+import (
+	"strings"
+	"testing"
+)
+
+func planAdult(elem testUser) bool {
+	return elem.Age >= 18
+}
+
+func planUserID(elem testUser) int {
+	return elem.ID
+}
+
+func TestMarshalPlan(t *testing.T) {
+	RegisterSource("planUsers", testData)
+	RegisterFunc("adult", planAdult)
+	RegisterFunc("userID", planUserID)
+
+	origin := OfSource("planUsers").Filter(planAdult).Limit(1).Map(planUserID)
+	plan, err := origin.MarshalPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := UnmarshalPlan(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedResult := []int{}
+	origin.Scan(&expectedResult)
+	result := []int{}
+	rebuilt.Scan(&result)
+	assertEquals(t, result, expectedResult)
+	assertEquals(t, result, []int{3})
+
+	_, err = OfSource("planUsers").Filter(func(elem testUser) bool {
+		return true
+	}).MarshalPlan()
+	if err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("expected not registered error, but return %v", err)
+	}
+	_, err = OfSlice(testData).MarshalPlan()
+	if err == nil {
+		t.Error("expected error for stream not created by OfSource")
+	}
+	_, err = UnmarshalPlan([]byte(`[{"op":"source","source":"planUsers","parallel":1},{"op":"map","parallel":1}]`))
+	if err == nil {
+		t.Error("expected error for map without func")
+	}
+}
@@ -0,0 +1,59 @@
+package streamv3
+
+import (
+	"sync"
+	"time"
+)
+
+// poolIdleTimeout worker空闲超过该时间后退出
+var poolIdleTimeout = time.Second
+
+// workerPool WithPool使用的worker pool
+// worker按需创建，最多size个，空闲超过poolIdleTimeout后退出，因此不需要显式关闭
+type workerPool struct {
+	size    int
+	tasks   chan func()
+	mu      sync.Mutex
+	workers int
+}
+
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{
+		size:  size,
+		tasks: make(chan func()),
+	}
+}
+
+// submit 优先交给空闲的worker执行；没有空闲worker时，未达到size则创建新的worker，
+// 否则在新的goroutine中执行，不会阻塞调用者（task中再次提交任务时也不会死锁）
+func (pool *workerPool) submit(task func()) {
+	select {
+	case pool.tasks <- task:
+		return
+	default:
+	}
+	pool.mu.Lock()
+	if pool.workers < pool.size {
+		pool.workers++
+		pool.mu.Unlock()
+		go pool.work(task)
+		return
+	}
+	pool.mu.Unlock()
+	go task()
+}
+
+// work worker的主循环，执行完task后等待下一个task，空闲超时后退出
+func (pool *workerPool) work(task func()) {
+	for {
+		task()
+		select {
+		case task = <-pool.tasks:
+		case <-time.After(poolIdleTimeout):
+			pool.mu.Lock()
+			pool.workers--
+			pool.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+//go:build go1.23
+
+package streamv3
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+// seqGetter 以iter.Seq为数据源，each时逐个读取，getData时读取全部元素
+type seqGetter[T any] struct {
+	seq      iter.Seq[T]
+	released bool
+}
+
+func (getter *seqGetter[T]) getData() []interface{} {
+	data := []interface{}{}
+	getter.each(func(elem interface{}) bool {
+		data = append(data, elem)
+		return true
+	})
+	return data
+}
+
+func (getter *seqGetter[T]) each(yield func(elem interface{}) bool) {
+	if getter.released {
+		return
+	}
+	for elem := range getter.seq {
+		if !yield(elem) {
+			return
+		}
+	}
+}
+
+func (getter *seqGetter[T]) fresh() bool {
+	return true
+}
+
+func (getter *seqGetter[T]) release() {
+	getter.released = true
+}
+
+// OfSeq 以iter.Seq为数据源创建数据流，每次执行终结操作时都会重新遍历seq
+// 能逐个执行的链（参考Limit）只会从seq中读取需要的元素，因此可以配合Limit使用无界的seq
+func OfSeq[T any](seq iter.Seq[T]) SliceStream {
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      reflect.TypeOf((*T)(nil)).Elem(),
+		dataGetter: &seqGetter[T]{
+			seq: seq,
+		},
+	}
+}
+
+// Seq 将stream的结果作为iter.Seq返回，T需要和上游数据类型一致
+// 遍历时才执行stream，能逐个执行的链会在每个元素处理完后立即交给调用者，提前break时不会再处理后续元素
+func Seq[T any](stream SliceStream) iter.Seq[T] {
+	streamer, ok := stream.(*SliceStreamer)
+	if !ok {
+		panic(fmt.Errorf("stream must be *SliceStreamer, not %s", reflect.TypeOf(stream)))
+	}
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but Seq's type is %s", streamer.curType, rt))
+	}
+	return func(yield func(T) bool) {
+		// nil元素以nil interface{}保存，断言失败时得到T的零值
+		if streamer.iterate(func(elem interface{}) bool {
+			v, _ := elem.(T)
+			return yield(v)
+		}) {
+			return
+		}
+		scanResult := streamer.scan()
+		for i := 0; i < len(scanResult); i++ {
+			v, _ := scanResult[i].(T)
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
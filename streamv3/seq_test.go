@@ -0,0 +1,76 @@
+//go:build go1.23
+
+package streamv3
+
+import (
+	"slices"
+	"testing"
+)
+
+func init() {
+	seqNilSafetyCase = func(t *testing.T) {
+		u := testData[0]
+		users := []*testUser{}
+		for user := range Seq[*testUser](OfSlice([]*testUser{nil, &u})) {
+			users = append(users, user)
+		}
+		assertEquals(t, users, []*testUser{nil, &u})
+
+		values := []interface{}{}
+		for value := range Seq[interface{}](OfSlice([]interface{}{nil, 1}).Sorted(func(a, b interface{}) bool {
+			return a == nil && b != nil
+		})) {
+			values = append(values, value)
+		}
+		assertEquals(t, values, []interface{}{nil, 1})
+	}
+}
+
+func TestOfSeq(t *testing.T) {
+	result := []int{}
+	OfSeq(slices.Values([]int{5, 1, 4, 2, 3})).Filter(func(elem int) bool {
+		return elem > 1
+	}).Sorted(func(a, b int) bool {
+		return a < b
+	}).Scan(&result)
+	assertEquals(t, result, []int{2, 3, 4, 5})
+
+	naturals := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+	OfSeq(naturals).Map(func(elem int) int {
+		return elem * elem
+	}).Limit(4).Scan(&result)
+	assertEquals(t, result, []int{0, 1, 4, 9})
+}
+
+func TestSeq(t *testing.T) {
+	names := []string{}
+	for name := range Seq[string](streamer.Map(func(elem testUser) string {
+		return elem.Name
+	})) {
+		names = append(names, name)
+	}
+	assertEquals(t, names, []string{"zhangsan", "lisi", "wangwu", "zhaoliu"})
+
+	processed := 0
+	ids := []int{}
+	for id := range Seq[int](OfRange(0, 100, 1).Peek(func(elem int) {
+		processed++
+	})) {
+		if id == 3 {
+			break
+		}
+		ids = append(ids, id)
+	}
+	assertEquals(t, ids, []int{0, 1, 2})
+	assertEquals(t, processed, 4)
+
+	ages := slices.Collect(Seq[int](streamer.Map(func(elem testUser) int {
+		return elem.Age
+	}).Sorted(func(a, b int) bool {
+		return a > b
+	}).Limit(2)))
+	assertEquals(t, ages, []int{25, 20})
+}
@@ -1,12 +1,19 @@
 package streamv3
 
 import (
+	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"reflect"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 )
 
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -22,27 +29,111 @@ type SliceStream interface {
 	// 让每个goroutine上承担 n/k 的数据量（无法整除则将剩余数据并入最后一个goroutine）
 	// streamer默认继承上一个streamer的并行度，如果没有上一个streamer，那么默认并行度为1。
 	// 在某个操作上设置新的并行度，不会影响之前的操作的并行度。
+	// Parallel不会修改当前的streamer，而是返回设置了新并行度的副本，因此可以从同一个streamer出发以不同的并行度构建多条链。
 	// 例如：
 	// 		源数据较多，执行filter时可以设置较大的并行度，从而提高效率；
 	// 		经过filter后的数据量已经不多了，那么可以在map上设置较小的并行度；
 	// 		从而避免创建过多goroutine。
 	// 上面说到并行度不是全局的概念，但可以通过某些操作实现全局的并行度设置。
 	// 即可以在最初的streamer上设置全局并行度k，随后不再设置并行度，从而实现全局并行度k。
+	// 并行度会被限制在[1, 2 * cpu_num]之间，超出的部分会被静默截断；IO密集的操作可以使用ParallelUnbounded
+	// 无论并行度多少（包括工作窃取模式和worker pool），Filter/Map/FlatMap等操作的结果都保持输入的顺序，和串行执行的结果完全相同，
+	// 因此可以放心地在并行操作之后使用Limit/First等依赖顺序的操作
 	Parallel(parallel int) SliceStream
+	// 和Parallel相同，但不限制并行度的上限（至少为1），适用于每个元素都要等待网络等IO的操作
+	// 并行度为k时每个操作最多会创建k个goroutine（不超过数据量），请根据下游的承受能力设置
+	ParallelUnbounded(parallel int) SliceStream
+	// 开启工作窃取模式，和并行度一样会被之后的操作继承。
+	// 默认情况下filter/map/flatMap会把数据按连续区间平均分给每个goroutine，若每个元素的处理耗时差别很大，
+	// 部分goroutine会提前结束而空闲。工作窃取模式下，goroutine通过共享的下标动态领取下一个元素，
+	// 从而平衡不均匀的负载，结果仍按原有顺序排列。
+	WithWorkStealing() SliceStream
+	// 使用最多size个常驻goroutine组成的worker pool执行filter/map/flatMap等操作的并行任务，和并行度一样会被之后的操作继承。
+	// 默认情况下每个操作都会创建parallel个新的goroutine，链上操作较多时会反复创建和销毁goroutine；
+	// 使用pool后空闲的worker会被复用，空闲一段时间后自动退出。worker都在忙时任务会在新的goroutine中执行，避免嵌套使用时死锁。
+	// 通常在最初的streamer上设置，同一个pool被整条链共用
+	WithPool(size int) SliceStream
+	// 复制整条stream链，并将链上每一个节点的并行度都设置为parallel。
+	// 和Parallel一样不修改原有的链，但WithParallelAll也会影响之前的操作的并行度，便于对同一条链用不同并行度做对比。
+	WithParallelAll(parallel int) SliceStream
 	// 根据filter func过滤符合条件的elem
 	// filter参数应为 func (item T) bool，T为上游数据类型
+	// 并行执行时保留的元素保持输入的顺序
 	Filter(filter ...interface{}) SliceStream
+	// 根据元素的下标（从0开始，按到达该操作的顺序编号）和元素本身过滤，适用于隔行取值、跳过表头等按位置过滤的场景
+	// pred按顺序串行执行，不使用并行
+	// pred参数应为 func (index int, item T) bool，T为上游数据类型
+	FilterIndexed(pred interface{}) SliceStream
 	// 根据mapper func将stream中的elem对象转化成另一种对象
 	// mapper参数应为 func (item T) O，T为上游数据类型，O为产出的新数据类型
+	// mapper也可以为 func (item T) (O, error)，返回error的元素会被丢弃，
+	// Scan等终结操作遇到error时会panic，ScanCollectErrors则会收集所有error
+	// 并行执行时结果保持输入的顺序
 	Map(mapper interface{}) SliceStream
 	// 根据mapper func将stream中的elem对象转化成另一种对象
 	// mapper参数应为 func (item T) []O，T为上游数据类型，O为产出的新数据类型，并将[]O打平
+	// 并行执行时按输入元素的顺序拼接每个元素产出的[]O
 	FlatMap(mapper interface{}) SliceStream
+	// 将上游的全部元素作为一个slice交给mapper，mapper返回的slice作为新的stream，适用于归一化、排名等需要全局信息的转换
+	// mapper串行执行且只执行一次
+	// mapper参数应为 func (items []T) []O，T为上游数据类型，O为产出的新数据类型
+	MapAll(mapper interface{}) SliceStream
+	// 和FlatMap类似，但mapper通过调用emit产出0个或多个元素，不需要为每个元素分配slice
+	// mapper参数应为 func (item T, emit func(O))，T为上游数据类型，O为产出的新数据类型
+	MapEmit(mapper interface{}) SliceStream
+	// 和Map相同，但mapper同时接收元素的下标（从0开始，按到达该操作的顺序编号），适用于生成行号等依赖位置的转换
+	// 放在Filter之后时为过滤后剩余的元素编号，放在Sorted之后时按排序后的顺序编号；Offset/Limit作用于最终结果，不影响编号
+	// mapper按顺序串行执行，不使用并行
+	// mapper参数应为 func (index int, item T) O，T为上游数据类型，O为产出的新数据类型
+	MapIndexed(mapper interface{}) SliceStream
+	// 对经过的每个元素执行op，元素原样向下游传递，用于调试等副作用场景
+	// 和Foreach不同，Peek是惰性的，可以继续链式调用；op按元素顺序串行执行，每个到达Peek的元素执行且只执行一次
+	// op参数应为 func (item T)，T为上游数据类型
+	Peek(op interface{}) SliceStream
+	// 依次取元素，直到第一个满足pred的元素为止（不包含该元素），之后的元素全部丢弃
+	// pred按元素顺序串行执行，遇到第一个满足的元素后不再调用
+	// pred参数应为 func (item T) bool，T为上游数据类型
+	TakeUntil(pred interface{}) SliceStream
+	// 将当前的元素顺序反转，例如 Sorted(...).Reverse().Limit(3) 取排序后的最后3个
+	Reverse() SliceStream
+	// 去除重复元素（使用==比较），保留第一次出现的顺序，T必须是可比较的类型
+	// T为interface或包含interface字段时，slice、map等不可比较的值使用reflect.DeepEqual比较
+	Distinct() SliceStream
+	// 根据keyer func分组，并将每个分组作为一个Group元素继续进入stream，之后可以对分组进行Sorted/Filter/Limit等操作
+	// 分组按key第一次出现的顺序排列
+	// keyer参数应为 func (item T) K，T为上游数据类型，K必须是可比较的类型；下游数据类型为Group
+	GroupByStream(keyer interface{}) SliceStream
+	// 将连续的size个元素合并成一个[]T元素，最后一个分块可能不足size个，例如用于分批写入数据库
+	// 下游数据类型为[]T，T为上游数据类型
+	Chunk(size int) SliceStream
+	// 滑动窗口：每个窗口包含size个连续元素，相邻窗口的起点相隔step个元素，step小于size时窗口会重叠
+	// 剩余元素不足size个时不再产生窗口，例如 [1,2,3,4,5] Window(3, 1) 为 [1,2,3] [2,3,4] [3,4,5]
+	// 下游数据类型为[]T，T为上游数据类型
+	Window(size, step int) SliceStream
+	// 将other的所有元素追加到当前stream之后，两者的数据类型必须相同
+	// 两条stream在执行终结操作时分别求值后按顺序合并，各自的Offset/Limit只作用于自己
+	// 返回的stream是一条新链的起点，并行度继承当前stream
+	Concat(other SliceStream) SliceStream
+	// 缓存当前stream的结果，第一次执行终结操作时求值，之后的终结操作直接复用缓存，不会重复执行之前的操作
+	// 返回的stream是一条新链的起点，并行度继承当前stream；可以在缓存之后继续添加操作，多个终结操作共享同一份缓存
+	// 缓存是并发安全的：多个goroutine同时执行终结操作时只会求值一次
+	Cache() SliceStream
 	// 跳过前n条记录
 	Offset(n int) SliceStream
+	// 和Offset相同，跳过前n条记录
+	Skip(n int) SliceStream
+	// 去掉最后n条记录，例如忽略末尾的汇总行，n为0时不做任何处理，n为负数时panic
+	// 和Offset/Limit一样作用于整条链的最终结果（即Filter/Sorted等所有操作之后），与调用的位置无关，多次调用时以最后一次为准；
+	// 执行顺序为：先去掉最后n条，再跳过Offset条，最后取Limit条，
+	// 例如 [1, 2, 3, 4, 5] 上的 SkipLast(1).Offset(1).Limit(2) 结果为 [2, 3]
+	SkipLast(n int) SliceStream
 	// 取前n条记录
+	// 链上没有Sorted/Reverse/GroupByStream/Chunk/Window/MapAll等需要全部数据的操作且没有设置并行时，会逐个元素执行并在取够n条后提前结束，
+	// 此时Peek等操作也只作用于实际被处理的元素；设置了并行时仍然按批并行执行整条链
 	Limit(n int) SliceStream
 	// 根据sorter的排序规则进行排序，sorter的结果为true则为降序，为false为升序
+	// 排序是稳定的，sorter认为相等的元素保持原有顺序
+	// 并行度大于1且元素较多时，会切分成parallel段并行排序后再归并，结果和串行排序完全相同，此时sorter需要是并发安全的
 	// sorter参数应为 func (item1, item2 T) bool，T为上游数据类型
 	Sorted(sorter interface{}) SliceStream
 
@@ -53,33 +144,208 @@ type SliceStream interface {
 	// 遍历所有结果，对每个结果执行希望的op func
 	// foreachOp参数应为 func (item T)，T为上游数据类型
 	Foreach(foreachOps ...interface{})
+	// 按顺序遍历所有结果，对每个结果执行op，同时传入元素的下标（从0开始计数）
+	// 下标是结果中的位置，和并行度无关
+	// op参数应为 func (index int, item T)，T为上游数据类型
+	ForeachIndexed(op interface{})
 	// 将结果读取出来，调用者根据stream中的元素类型，传入相应的slice pointer
 	// result参数应为 []T类型，T为上游数据类型
 	Scan(result interface{})
+	// 和Scan相同，但每个元素写入result后立即对其执行op，相当于Peek+Scan而不需要额外的节点
+	// op参数应为 func (item T)；result参数应为[]T类型，T为上游数据类型
+	ScanEach(op interface{}, result interface{})
+	// 分页读取结果，将第[offset, offset+limit)个元素写入result，并返回分页前的元素总数，不需要额外的Count
+	// offset小于0时按0处理，limit小于等于0时表示不限制数量；result参数应为*[]T类型，T为上游数据类型
+	ScanPage(offset, limit int, result interface{}) (total int)
+	// 和Scan相同，但遇到mapper返回的error不会中止，而是收集起来由errs带出
+	// result参数应为 []T类型，T为上游数据类型；errs参数应为 []error类型，按元素顺序排列
+	ScanCollectErrors(result interface{}, errs interface{})
+	// 和Scan相同，但执行过程中（包括并行的goroutine中）的panic会被转换为error返回，而不是panic
+	// result参数应为 []T类型，T为上游数据类型
+	TryScan(result interface{}) error
+	// 和Count相同，但panic会被转换为error返回
+	TryCount() (int, error)
+	// 和Foreach相同，但panic会被转换为error返回
+	TryForeach(foreachOps ...interface{}) error
+	// 和Scan相同，但取出结果后会释放源数据，使源数据可以被gc回收
+	// 注意：源数据被所有共享同一个源的stream共用，释放后这些stream再执行终结操作都只会得到空结果
+	ScanAndRelease(result interface{})
+	// 在读取结果的同时通过converter转换类型，不需要额外的Map操作
+	// converter参数应为 func (item T) O 或 func (item T) (O, bool)，后者返回false时丢弃该元素
+	// result参数应为 []O类型
+	ScanAs(converter interface{}, result interface{})
+	// 按顺序将每个结果发送到ch，不会关闭ch（ch由调用者管理），发送完所有结果后返回
+	// 链上没有需要全部数据的操作时，每个元素处理完即发送，不会先构造完整的结果
+	// ch参数应为可发送的chan T或chan<- T，T为上游数据类型
+	ToChannel(ch interface{})
+	// 和ToChannel相同，但发送完所有结果后关闭ch
+	ToChannelClose(ch interface{})
+	// 将结果作为[]T序列化为JSON数组，没有结果时返回[]
+	ToJSON() ([]byte, error)
+	// 将结果按size切分成批次，通过channel逐批发送，全部发送后关闭channel，消费者可以边接收边处理
+	// 每个批次是一个[]T（最后一个批次可能不足size个），T为上游数据类型，需要调用者自行断言
+	// 链上没有Sorted/Reverse/GroupByStream/Chunk/Window/MapAll等需要全部数据的操作且没有设置并行时，整条链在单独的goroutine中逐个元素执行，
+	// 每攒够size个元素就发送一个批次，消费者不需要等待上游全部处理完；否则在调用BatchChannel时先执行完整条链再逐批发送。
+	// 逐个元素执行时发生的panic会被恢复，以*PanicError作为最后一个元素发送，之后关闭channel，消费者需要检查收到的元素类型；
+	// 消费者必须读完channel，否则发送的goroutine会一直阻塞
+	BatchChannel(size int) <-chan interface{}
+	// 将结果写入result已有的位置，最多写入len(result)个元素，不会扩容，返回写入的元素数
+	// result参数应为 []T 或 [N]T 类型的指针，T为上游数据类型
+	ScanN(result interface{}) int
+	// 和Scan相同，但会对每个元素做深拷贝，修改结果不会影响源数据（适用于[]*T或包含slice/map的结构体）
+	// 深拷贝通过反射逐层复制，开销远大于Scan；结构体中未导出的字段无法通过反射设置，只做浅拷贝
+	// result参数应为 []T类型，T为上游数据类型
+	ScanCopy(result interface{})
 	// 根据getKey func获取key，并做聚合。聚合结果由result带出。
+	// 并行时每个分组内的元素仍然保持原有顺序
 	// keyer参数应为 func (item T) K ，T为上游数据类型，K为 groupby key的类型
 	// result参数应为map[K][]T
 	GroupBy(keyer interface{}, result interface{})
+	// 根据keyer func获取key，并统计每个key的元素数，结果由result带出。
+	// 和GroupBy相同，多个goroutine各自计数后合并，但不需要为每个分组保存元素
+	// keyer参数应为 func (item T) K ，T为上游数据类型，K必须是可比较的类型
+	// result参数应为map[K]int
+	CountBy(keyer interface{}, result interface{})
+	// 根据keyer func分组，并用accumulator将每个分组聚合成一个值，结果由result带出，不需要像GroupBy一样保存每个分组的所有元素
+	// 每个分组的第一个元素作为初始值，之后按元素顺序依次调用accumulator(已聚合的值, 元素)，只有一个元素的分组不会调用accumulator
+	// keyer参数应为 func (item T) K，K必须是可比较的类型；accumulator参数应为 func (a, b T) T
+	// result参数应为map[K]T
+	GroupByReduce(keyer, accumulator interface{}, result interface{})
 	// 根据getKey func获取key，结果由result带出。
 	// ToMap和GroupBy的区别是，ToMap需要调用者保证key的唯一性，若数据中key重复，会直接覆盖
 	// keyer参数应为 func (item T) K ，T为上游数据类型，K为 tomap key的类型
 	// result参数应为map[K]T
 	ToMap(keyer interface{}, result interface{})
-	// 获取结果中的第一个
+	// 根据keyer获取key、valuer获取value，结果由result带出，不需要先Map再ToMap；key重复时和ToMap一样后面的元素覆盖前面的元素
+	// keyer参数应为 func (item T) K，K必须是可比较的类型；valuer参数应为 func (item T) V
+	// result参数应为map[K]V
+	Associate(keyer, valuer interface{}, result interface{})
+	// 根据keyer分组，对每个分组执行aggregators中的每个聚合函数，每个分组产出一行ReportRow，结果按key升序排列
+	// keyer参数应为 func (item T) K，K必须是整数、浮点数或string类型；
+	// aggregators的value应为 func (items []T) R，ReportRow.Values中以相同的名称保存R；result参数应为[]ReportRow
+	Report(keyer interface{}, aggregators map[string]interface{}, result interface{})
+	// 将结果转换成set，结果由result带出，T必须是可比较的类型
+	// result参数应为map[T]struct{}，T为上游数据类型
+	ToSet(result interface{})
+	// 以元素的下标（从0开始计数）为key，将结果转换成map，结果由result带出，便于随机访问
+	// result参数应为map[int]T，T为上游数据类型
+	ToIndexMap(result interface{})
+	// 根据keyer计算每个key的元素数占总数的比例（0到1之间），结果由result带出，stream为空时result为空map
+	// keyer参数应为 func (item T) K，K必须是可比较的类型；result参数应为map[K]float64
+	PercentByKey(keyer interface{}, result interface{})
+	// 获取结果中的第一个，和Limit一样在可以逐个执行时取到第一个结果后即提前结束
 	// result参数应为T类型，T为上游数据类型
 	First(result interface{}) bool
 	// 获取结果中的最后一个
 	// result参数应为T类型，T为上游数据类型
 	Last(result interface{}) bool
-	// 获取结果中的第index个（从0开始计数）
+	// 获取结果中的第index个（从0开始计数），和First一样在可以逐个执行时取够index+1个结果后即提前结束
 	// result参数应为T类型，T为上游数据类型
 	IndexAt(index int, result interface{}) bool
 	// 获取元素数
 	Count() int
+	// 判断结果是否已经按less排好序（非降序），比排序更轻量
+	// less参数应为 func (item1, item2 T) bool，T为上游数据类型，item1 < item2时返回true
+	IsSorted(less interface{}) bool
+	// 找出keyer结果重复的元素，结果由result带出，用于校验数据唯一性
+	// result包含key出现多于一次的所有元素（包括第一次出现的），按原有顺序排列
+	// keyer参数应为 func (item T) K，K必须是可比较的类型；result参数应为[]T，T为上游数据类型
+	FindDuplicates(keyer interface{}, result interface{})
+	// 校验keyer结果的唯一性，存在两个元素的key相同时返回error（包含key和这两个元素），否则返回nil
+	// 遇到第一个冲突即返回，不会构造结果slice
+	// keyer参数应为 func (item T) K，K必须是可比较的类型
+	AssertUnique(keyer interface{}) error
 	// 根据accumulator两两聚合，结果由result带出。
+	// 注意：result原有的值会作为初始值参与聚合（包括只有一个元素时），没有元素时result保持不变；
+	// 需要明确初始值时请使用Fold
 	// accumulator参数应为 func (item1, item2 T) T ，T为上游数据类型
 	// result参数应为T类型
 	Reduce(accumulator interface{}, result interface{})
+	// 从identity开始，通过accumulator依次聚合每一个元素，结果由result带出，stream为空时result为identity
+	// accumulator参数应为 func (acc T, item T) T ，T为上游数据类型
+	// identity和result参数都应为T类型
+	Fold(identity interface{}, accumulator interface{}, result interface{})
+	// 通用的收集操作：通过supplier创建一个容器，按顺序将每个元素通过accumulator放入容器，结果由result带出
+	// 可以用来构建set、计数map、strings.Builder等，容器只创建一次，accumulator串行执行
+	// supplier参数应为 func () A；accumulator参数应为 func (acc A, item T)，通过修改acc收集元素，
+	// 因此A通常是指针、map等引用类型；result参数应为A类型
+	Collect(supplier, accumulator interface{}, result interface{})
+	// 滚动窗口聚合：将结果按size切分成互不重叠的窗口（最后一个窗口可能不足size个），
+	// 每个窗口从init开始通过accumulator聚合成一个值，结果由result带出。
+	// accumulator参数应为 func (acc A, item T) A，init参数应为A类型，result参数应为[]A
+	TumblingAggregate(size int, accumulator interface{}, init interface{}, result interface{})
+	// 将结果切分成n个连续的分片，结果由result带出。
+	// 分片大小尽量相等，无法整除时前 len%n 个分片各多一个元素，例如10个元素分3片为[4,3,3]；
+	// 元素数不足n时，靠后的分片为空。
+	// result参数应为[][]T类型，T为上游数据类型
+	Shard(n int, result interface{})
+	// 求和，结果由result带出，T必须是数值类型（int、int64、float64等）
+	// result参数应为T类型，T为上游数据类型
+	Sum(result interface{})
+	// 求平均值，结果由result带出，T必须是数值类型，stream为空时result为0
+	// result参数应为float64类型
+	Average(result interface{})
+	// 对extractor提取的数值求和，结果由result带出，不需要先Map成数值再Sum
+	// extractor参数应为 func (item T) N，N为数值类型；result参数应为N类型
+	SumBy(extractor interface{}, result interface{})
+	// 对extractor提取的数值求平均值，结果由result带出，stream为空时result为0
+	// extractor参数应为 func (item T) N，N为数值类型；result参数应为float64类型
+	AverageBy(extractor interface{}, result interface{})
+	// 求加权平均值 sum(value*weight)/sum(weight)，结果由result带出；stream为空或总权重为0时返回false
+	// valueSel和weightSel参数都应为 func (item T) N，N为数值类型
+	WeightedAverage(valueSel, weightSel interface{}, result *float64) bool
+	// 获取最小的元素，只遍历一次，不需要排序。stream为空时返回false，不修改result
+	// less参数应为 func (item1, item2 T) bool，item1 < item2时返回true；result参数应为T类型
+	Min(less interface{}, result interface{}) bool
+	// 获取最大的元素，只遍历一次，不需要排序。stream为空时返回false，不修改result
+	// less参数应为 func (item1, item2 T) bool，item1 < item2时返回true；result参数应为T类型
+	Max(less interface{}, result interface{}) bool
+	// 获取keyer返回值最小的元素，只遍历一次，每个元素只调用一次keyer。多个元素的key相同时取最先出现的元素，stream为空时返回false，不修改result
+	// keyer参数应为 func (item T) K，K为整数、浮点数或字符串类型；result参数应为T类型
+	MinBy(keyer interface{}, result interface{}) bool
+	// 获取keyer返回值最大的元素，只遍历一次，每个元素只调用一次keyer。多个元素的key相同时取最先出现的元素，stream为空时返回false，不修改result
+	// keyer参数应为 func (item T) K，K为整数、浮点数或字符串类型；result参数应为T类型
+	MaxBy(keyer interface{}, result interface{}) bool
+	// 获取按less排序后的第n个元素（从0开始计数），使用快速选择，期望时间复杂度为O(len)，比Sorted().IndexAt(n)更轻量
+	// n超出范围时返回false，不修改result
+	// less参数应为 func (item1, item2 T) bool，item1 < item2时返回true；result参数应为T类型
+	NthBy(n int, less interface{}, result interface{}) bool
+	// 累计最大值：结果的第i个元素为前i+1个元素中最大的元素，结果由result带出，例如 [1,3,2,5] 为 [1,3,3,5]
+	// 按元素顺序串行计算，相等时保留先出现的元素
+	// less参数应为 func (item1, item2 T) bool，item1 < item2时返回true；result参数应为[]T类型
+	CumulativeMax(less interface{}, result interface{})
+	// 对每个元素执行一次模板，输出写入w，模板的上下文为T类型的元素
+	Render(w io.Writer, tmpl *template.Template) error
+	// 将所有结果作为整体执行一次模板，输出写入w，模板的上下文为[]T
+	RenderAll(w io.Writer, tmpl *template.Template) error
+	// 是否存在满足pred的元素，遇到第一个满足的元素即返回true
+	// pred参数应为 func (item T) bool，T为上游数据类型
+	AnyMatch(pred interface{}) bool
+	// 是否所有元素都满足pred，遇到第一个不满足的元素即返回false，stream为空时返回true
+	// pred参数应为 func (item T) bool，T为上游数据类型
+	AllMatch(pred interface{}) bool
+	// 是否没有元素满足pred，遇到第一个满足的元素即返回false，stream为空时返回true
+	// pred参数应为 func (item T) bool，T为上游数据类型
+	NoneMatch(pred interface{}) bool
+	// 只遍历一次，将满足pred的元素放入matched，不满足的放入unmatched
+	// pred参数应为 func (item T) bool；matched和unmatched参数都应为[]T，T为上游数据类型
+	Partition(pred interface{}, matched interface{}, unmatched interface{})
+	// 用sep连接所有元素（末尾没有sep），结果由result带出，T必须是string类型
+	// result参数应为string类型
+	Joining(sep string, result interface{})
+	// 和Joining相同，但会在结果前后加上prefix和suffix
+	JoiningWith(sep, prefix, suffix string, result interface{})
+	// 计算和other的笛卡尔积，每一对(a, b)通过combiner合并成一个元素，结果由result带出
+	// 结果按a在外层、b在内层的顺序排列，元素数为两者元素数的乘积
+	// combiner参数应为 func (a A, b B) O，A为当前stream的数据类型，B为other的数据类型；result参数应为[]O
+	CrossJoin(other SliceStream, combiner interface{}, result interface{})
+	// 执行两条stream并按顺序逐个比较元素（使用reflect.DeepEqual），元素类型、元素数量和每个元素都相同时返回true
+	// 元素类型不同时不会执行任何一条stream，直接返回false；可以用于测试中的断言或比较两条链的结果
+	Equal(other SliceStream) bool
+	// 将stream链序列化成执行计划，计划中只保存函数和数据源的名称，不保存函数本身
+	// 链上的函数必须通过RegisterFunc注册，数据源必须通过OfSource创建，否则返回error
+	// 在其他进程中注册同名的函数和数据源后，可以通过UnmarshalPlan重建相同的stream链
+	MarshalPlan() ([]byte, error)
 }
 
 // SliceStreamer SliceStreamer
@@ -90,27 +356,49 @@ type SliceStreamer struct {
 	lastStreamer *SliceStreamer
 	dataGetter   DataGetter
 	parallel     int
+	workStealing bool
+	pool         *workerPool
 	filterFunc   []reflect.Value
 	mapFunc      *reflect.Value
 	flatMapFunc  *reflect.Value
+	emitFunc     *reflect.Value
+	mapAllFunc   *reflect.Value
+	indexFilter  *reflect.Value
+	indexMapper  *reflect.Value
 	sortFunc     *reflect.Value
+	peekFunc     *reflect.Value
+	untilFunc    *reflect.Value
+	distinct     bool
+	reverse      bool
+	groupFunc    *reflect.Value
+	windowSize   int
+	windowStep   int
+	keepTail     bool
 	offset       int
 	limit        int
+	skipLast     int
 	//data         []interface{}
 	curType      reflect.Type
 }
 
-// OfSlice 只接受slice类型
+// OfSlice 接受slice、数组以及它们的指针
+// 创建时不会复制data，每次执行终结操作时才从data中读取元素，因此之后对data元素的修改会反映到结果中；
+// 直接传入数组时Go会复制整个数组，之后对原数组的修改不会反映到结果中，需要时请传入数组指针
+// data为nil的slice或nil的slice指针、数组指针时为空的stream；元素类型为接口或指针时可以包含nil元素，nil元素会以零值传给filter/mapper等函数
 func OfSlice(data interface{}) SliceStream {
-	interfaceList := []interface{}{}
 	val := reflect.ValueOf(data)
 	dt := reflect.TypeOf(data)
 	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
 		dt = dt.Elem()
+		val = val.Elem()
+		// nil的slice指针或数组指针视为空的数据源
+		if !val.IsValid() && (dt.Kind() == reflect.Slice || dt.Kind() == reflect.Array) {
+			dt = reflect.SliceOf(dt.Elem())
+			val = reflect.Zero(dt)
+		}
 	}
-	if val.Kind() != reflect.Slice {
-		panic(fmt.Errorf("mapIter must be slice pointer, not %s", val.Elem().Kind()))
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		panic(fmt.Errorf("data must be slice, array or pointer to them, not %s", reflect.TypeOf(data)))
 	}
 	s := &SliceStreamer{
 		lastStreamer: nil,
@@ -120,30 +408,204 @@ func OfSlice(data interface{}) SliceStream {
 		sortFunc:     nil,
 		offset:       0,
 		limit:        0,
-		//data:         interfaceList,
 	}
 	s.curType = dt.Elem()
-	for i := 0; i < val.Len(); i++ {
-		interfaceList = append(interfaceList, val.Index(i).Interface())
-	}
 	s.dataGetter = &sliceGetter{
-		data: interfaceList,
+		val: val,
 	}
 	return s
 }
 
+// OfChannel 以channel为数据源，ch应为可接收的chan T或<-chan T
+// 第一次执行终结操作时才会从ch中读取数据，直到ch被关闭，之后的终结操作复用已读取的数据
+// channel只能被消费一次，同一个channel只应创建一个stream
+func OfChannel(ch interface{}) SliceStream {
+	val := reflect.ValueOf(ch)
+	if val.Kind() != reflect.Chan {
+		panic(fmt.Errorf("ch must be channel, not %s", val.Kind()))
+	}
+	if val.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Errorf("ch must be receive-capable, but %s is not", val.Type()))
+	}
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      val.Type().Elem(),
+		dataGetter: &channelGetter{
+			ch: val,
+		},
+	}
+}
+
+// OfLines 以r中的每一行（不包含行尾的换行符）为元素创建string类型的数据流
+// 第一次执行终结操作时才会读取r直到结束，之后的终结操作复用已读取的数据，读取失败时panic
+// 默认单行最长为bufio.MaxScanTokenSize（64KB），超过时panic，可以通过maxLineSize指定更大的单行长度
+func OfLines(r io.Reader, maxLineSize ...int) SliceStream {
+	scanner := bufio.NewScanner(r)
+	if len(maxLineSize) > 0 && maxLineSize[0] > 0 {
+		// 单行的最大长度为初始缓冲区容量和maxLineSize中较大的一个，初始缓冲区不能超过maxLineSize
+		initSize := 4096
+		if maxLineSize[0] < initSize {
+			initSize = maxLineSize[0]
+		}
+		scanner.Buffer(make([]byte, 0, initSize), maxLineSize[0])
+	}
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      reflect.TypeOf(""),
+		dataGetter: &linesGetter{
+			scanner: scanner,
+		},
+	}
+}
+
+// OfRange 生成从start（包含）到end（不包含）、步长为step的int数据流
+// step为负数时生成递减的数据，step不能为0
+func OfRange(start, end, step int) SliceStream {
+	if step == 0 {
+		panic(fmt.Errorf("step must not be 0"))
+	}
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      reflect.TypeOf(0),
+		dataGetter: &rangeGetter{
+			start: start,
+			end:   end,
+			step:  step,
+		},
+	}
+}
+
+// Iterate 生成seed, next(seed), next(next(seed)), ...的无界数据流
+// next参数应为 func (item T) T，T为seed的类型
+// 无界数据流必须通过Limit限制数量，且链上不能有Sorted/Reverse/GroupByStream/Chunk/Window/MapAll等需要全部数据的操作，否则终结操作会panic
+func Iterate(seed interface{}, next interface{}) SliceStream {
+	seedVal := reflect.ValueOf(seed)
+	if !seedVal.IsValid() {
+		panic(errors.New("seed must not be nil"))
+	}
+	fv := reflect.ValueOf(next)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("next must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 || ft.NumOut() != 1 {
+		panic(fmt.Errorf("next must be func (item T) T, not %s", ft))
+	}
+	if ft.In(0) != seedVal.Type() || ft.Out(0) != seedVal.Type() {
+		panic(fmt.Errorf("seed's type is %s, but next's type is %s", seedVal.Type(), ft))
+	}
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      seedVal.Type(),
+		dataGetter: &iterateGetter{
+			seed: seedVal,
+			next: fv,
+		},
+	}
+}
+
+// FromJSON 将JSON数组解析为[]T并创建数据流，elemPtr为元素类型的指针，用于确定T，如(*T)(nil)
+// JSON格式错误时返回error
+func FromJSON(data []byte, elemPtr interface{}) (SliceStream, error) {
+	rt := reflect.TypeOf(elemPtr)
+	if rt == nil || rt.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("elemPtr must be pointer, not %s", rt))
+	}
+	val := reflect.New(reflect.SliceOf(rt.Elem()))
+	if err := json.Unmarshal(data, val.Interface()); err != nil {
+		return nil, err
+	}
+	return OfSlice(val.Elem().Interface()), nil
+}
+
+// newSliceStreamer 用已经转换好的数据创建头节点
+func newSliceStreamer(data []interface{}, curType reflect.Type) *SliceStreamer {
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     1,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      curType,
+		dataGetter: &sliceGetter{
+			data: data,
+		},
+	}
+}
+
 // Parallel 设置并行度
 func (streamer *SliceStreamer) Parallel(parallel int) SliceStream {
-	// at least 1 parallel
+	cloned := *streamer
+	cloned.parallel = fixParallel(parallel)
+	return &cloned
+}
+
+// ParallelUnbounded 设置并行度，不限制上限
+func (streamer *SliceStreamer) ParallelUnbounded(parallel int) SliceStream {
 	if parallel <= 0 {
 		parallel = 1
 	}
-	// max parallel = 2 * cpu_num
-	if parallel > runtime.NumCPU()*2 {
-		parallel = runtime.NumCPU() * 2
+	cloned := *streamer
+	cloned.parallel = parallel
+	return &cloned
+}
+
+// WithPool 使用worker pool执行并行任务
+func (streamer *SliceStreamer) WithPool(size int) SliceStream {
+	if size <= 0 {
+		panic(fmt.Errorf("pool size can't less than or equal 0, but your args is %d", size))
+	}
+	cloned := *streamer
+	cloned.pool = newWorkerPool(size)
+	return &cloned
+}
+
+// WithWorkStealing 开启工作窃取模式
+func (streamer *SliceStreamer) WithWorkStealing() SliceStream {
+	cloned := *streamer
+	cloned.workStealing = true
+	return &cloned
+}
+
+// WithParallelAll 复制整条链，并将每个节点的并行度设置为parallel
+func (streamer *SliceStreamer) WithParallelAll(parallel int) SliceStream {
+	parallel = fixParallel(parallel)
+	streamerList := streamer.chain()
+	cloned := make([]SliceStreamer, len(streamerList))
+	for i := len(streamerList) - 1; i >= 0; i-- {
+		cloned[i] = *streamerList[i]
+		cloned[i].parallel = parallel
+		if i < len(streamerList)-1 {
+			cloned[i].lastStreamer = &cloned[i+1]
+		}
 	}
-	streamer.parallel = parallel
-	return streamer
+	return &cloned[0]
 }
 
 // Filter 过滤规则，filter的参数elem是stream中的元素
@@ -179,10 +641,49 @@ func (streamer *SliceStreamer) Filter(filters ...interface{}) SliceStream {
 	return &SliceStreamer{
 		lastStreamer: streamer,
 		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
 		filterFunc:   fvs,
 		mapFunc:      nil,
 		sortFunc:     nil,
 		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		limit:        streamer.limit,
+		curType:      streamer.curType,
+	}
+}
+
+// FilterIndexed 带下标的过滤规则，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) FilterIndexed(pred interface{}) SliceStream {
+	fv := reflect.ValueOf(pred)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("pred must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("pred's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if ft.In(0) != reflect.TypeOf(0) {
+		panic(fmt.Errorf("pred's first args type must be int, not %s", ft.In(0)))
+	}
+	ip2 := ft.In(1)
+	if streamer.curType != ip2 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but pred's second args type is %s", streamer.curType, ip2))
+	}
+	if ft.NumOut() != 1 || ft.Out(0).Kind() != reflect.Bool {
+		panic(fmt.Errorf("pred's return-val type should be bool"))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		indexFilter:  &fv,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
 		limit:        streamer.limit,
 		curType:      streamer.curType,
 	}
@@ -205,16 +706,23 @@ func (streamer *SliceStreamer) Map(mapper interface{}) SliceStream {
 		panic(fmt.Errorf("upstream mapIter's type is %s, but mapper's args type is %s", streamer.curType, ip1))
 	}
 
-	if ft.NumOut() != 1 {
-		panic(fmt.Errorf("mapper's output number must equals 1, not %d", ft.NumOut()))
+	if ft.NumOut() == 2 {
+		if ft.Out(1) != errorType {
+			panic(fmt.Errorf("mapper's second return-val type should be error, not %s", ft.Out(1)))
+		}
+	} else if ft.NumOut() != 1 {
+		panic(fmt.Errorf("mapper's output number must equals 1 or 2, not %d", ft.NumOut()))
 	}
 	return &SliceStreamer{
 		lastStreamer: streamer,
 		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
 		filterFunc:   nil,
 		mapFunc:      &fv,
 		sortFunc:     nil,
 		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
 		limit:        streamer.limit,
 		curType:      ft.Out(0),
 	}
@@ -247,286 +755,1710 @@ func (streamer *SliceStreamer) FlatMap(flatMapper interface{}) SliceStream {
 	return &SliceStreamer{
 		lastStreamer: streamer,
 		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
 		filterFunc:   nil,
 		mapFunc:      nil,
 		flatMapFunc:  &fv,
 		sortFunc:     nil,
 		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
 		limit:        streamer.limit,
 		curType:      op1.Elem(),
 	}
 }
 
-// Limit 取前n条记录，惰性操作，只在执行了终结操作时起作用
-func (streamer *SliceStreamer) Limit(n int) SliceStream {
-	if n <= 0 {
-		panic(fmt.Errorf("limit rows can't less than or equal 0, but your args is %d", n))
+// MapAll 对上游的全部元素做整体转换，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) MapAll(mapper interface{}) SliceStream {
+	fv := reflect.ValueOf(mapper)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("mapper must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("mapper's args number must equals 1, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if reflect.SliceOf(streamer.curType) != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but mapper's args type is %s", streamer.curType, ip1))
+	}
+
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("mapper's output number must equals 1, not %d", ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if op1.Kind() != reflect.Slice {
+		panic(fmt.Errorf("mapper's output must be slice"))
 	}
 	return &SliceStreamer{
 		lastStreamer: streamer,
 		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
 		filterFunc:   nil,
 		mapFunc:      nil,
+		mapAllFunc:   &fv,
 		sortFunc:     nil,
-		limit:        n,
 		offset:       streamer.offset,
-		curType:      streamer.curType,
+		skipLast:     streamer.skipLast,
+		limit:        streamer.limit,
+		curType:      op1.Elem(),
 	}
 }
 
-// Offset 跳过前n条记录，惰性操作，只在执行了终结操作时起作用
-func (streamer *SliceStreamer) Offset(n int) SliceStream {
-	if n <= 0 {
-		panic(fmt.Errorf("offset rows can't less than or equal 0, but your args is %d", n))
+// MapIndexed 带下标的转化规则，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) MapIndexed(mapper interface{}) SliceStream {
+	fv := reflect.ValueOf(mapper)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("mapper must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("mapper's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if ft.In(0) != reflect.TypeOf(0) {
+		panic(fmt.Errorf("mapper's first args type must be int, not %s", ft.In(0)))
+	}
+	ip2 := ft.In(1)
+	if streamer.curType != ip2 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but mapper's second args type is %s", streamer.curType, ip2))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("mapper's output number must equals 1, not %d", ft.NumOut()))
 	}
 	return &SliceStreamer{
 		lastStreamer: streamer,
 		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
 		filterFunc:   nil,
 		mapFunc:      nil,
+		indexMapper:  &fv,
 		sortFunc:     nil,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
 		limit:        streamer.limit,
-		offset:       n,
-		curType:      streamer.curType,
+		curType:      ft.Out(0),
 	}
 }
 
-// Sorted 排序
-func (streamer *SliceStreamer) Sorted(sorter interface{}) SliceStream {
-	fv := reflect.ValueOf(sorter)
+// MapEmit 通过emit产出0个或多个元素，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) MapEmit(mapper interface{}) SliceStream {
+	fv := reflect.ValueOf(mapper)
 	if fv.Kind() != reflect.Func {
-		panic(fmt.Errorf("sorter must be a function, not %s", fv.Kind()))
+		panic(fmt.Errorf("mapper must be a function, not %s", fv.Kind()))
 	}
 	ft := fv.Type()
 	if ft.NumIn() != 2 {
-		panic(fmt.Errorf("sorter's args number must equals 2, not %d", ft.NumIn()))
+		panic(fmt.Errorf("mapper's args number must equals 2, not %d", ft.NumIn()))
 	}
+
 	ip1 := ft.In(0)
-	ip2 := ft.In(1)
-	if ip1 != ip2 {
-		panic(fmt.Errorf("sorter: first param type (%s) is different with second param type (%s)", ip1, ip2))
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but mapper's first args type is %s", streamer.curType, ip1))
 	}
 
-	if ip1 != streamer.curType {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but sorter's args type is %s", streamer.curType, ip1))
+	emitType := ft.In(1)
+	if emitType.Kind() != reflect.Func || emitType.NumIn() != 1 || emitType.NumOut() != 0 {
+		panic(fmt.Errorf("mapper's second args type must be func(O), not %s", emitType))
 	}
 
-	if ft.NumOut() != 1 {
-		panic(fmt.Errorf("sorter's output number must equals 1, not %d", ft.NumOut()))
+	if ft.NumOut() != 0 {
+		panic(fmt.Errorf("mapper's output number must equals 0, not %d", ft.NumOut()))
 	}
-	op1 := ft.Out(0)
-	if op1.Kind() != reflect.Bool {
-		panic(fmt.Errorf("sorter's return-val type should be bool, not %s", op1))
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		emitFunc:     &fv,
+		sortFunc:     nil,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		limit:        streamer.limit,
+		curType:      emitType.In(0),
+	}
+}
+
+// Peek 对每个元素执行op，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Peek(op interface{}) SliceStream {
+	fv := reflect.ValueOf(op)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("peekOp must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("peekOp's args number must equals 1, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but peekOp's args type is %s", streamer.curType, ip1))
 	}
 
+	if ft.NumOut() != 0 {
+		panic(fmt.Errorf("peekOp's output number must equals 0, not %d", ft.NumOut()))
+	}
 	return &SliceStreamer{
 		lastStreamer: streamer,
 		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
 		filterFunc:   nil,
 		mapFunc:      nil,
+		sortFunc:     nil,
+		peekFunc:     &fv,
 		limit:        streamer.limit,
 		offset:       streamer.offset,
-		sortFunc:     &fv,
+		skipLast:     streamer.skipLast,
 		curType:      streamer.curType,
 	}
 }
 
-// Foreach 遍历streamer中的每个元素
-func (streamer *SliceStreamer) Foreach(foreachOps ...interface{}) {
-	fvs := []reflect.Value{}
-	for i := 0; i < len(foreachOps); i++ {
-		foreachOp := foreachOps[i]
-		fv := reflect.ValueOf(foreachOp)
-		if fv.Kind() != reflect.Func {
-			panic(fmt.Errorf("foreachOp must be a function, not %s", fv.Kind()))
-		}
-		ft := fv.Type()
-		if ft.NumIn() != 1 {
-			panic(fmt.Errorf("foreachOp's args number must equals 1, not %d", ft.NumIn()))
-		}
-
-		ip1 := ft.In(0)
-		if streamer.curType != ip1 {
-			panic(fmt.Errorf("upstream mapIter's type is %s, but foreachOp's args type is %s", streamer.curType, ip1))
-		}
-
-		if ft.NumOut() != 0 {
-			panic(fmt.Errorf("foreachOp's output number must equals 0, not %d", ft.NumOut()))
-		}
-		fvs = append(fvs, fv)
+// TakeUntil 取元素直到第一个满足pred的元素（不包含），惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) TakeUntil(pred interface{}) SliceStream {
+	fv := streamer.checkPredicate("pred", pred)
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		untilFunc:    &fv,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      streamer.curType,
 	}
+}
 
-	result := streamer.scan()
-	for i := 0; i < len(result); i++ {
-		for j := 0; j < len(fvs); j++ {
-			_ = call(fvs[j], result[i])
-		}
+// Distinct 去重，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Distinct() SliceStream {
+	if !streamer.curType.Comparable() {
+		panic(fmt.Errorf("Distinct: upstream mapIter's type %s is not comparable", streamer.curType))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		distinct:     true,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      streamer.curType,
 	}
 }
 
-// Scan 将结果带出
-func (streamer *SliceStreamer) Scan(result interface{}) {
-	val := reflect.ValueOf(result)
+// Reverse 反转顺序，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Reverse() SliceStream {
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		reverse:      true,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      streamer.curType,
+	}
+}
+
+// GroupByStream 分组，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) GroupByStream(keyer interface{}) SliceStream {
+	fv := streamer.checkKeyer("keyer", keyer)
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		groupFunc:    &fv,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      reflect.TypeOf(Group{}),
+	}
+}
+
+// Chunk 将连续的size个元素合并成一个分块，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Chunk(size int) SliceStream {
+	if size <= 0 {
+		panic(fmt.Errorf("chunk size can't less than or equal 0, but your args is %d", size))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		windowSize:   size,
+		windowStep:   size,
+		keepTail:     true,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      reflect.SliceOf(streamer.curType),
+	}
+}
+
+// Window 滑动窗口，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Window(size, step int) SliceStream {
+	if size <= 0 {
+		panic(fmt.Errorf("window size can't less than or equal 0, but your args is %d", size))
+	}
+	if step <= 0 {
+		panic(fmt.Errorf("window step can't less than or equal 0, but your args is %d", step))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		windowSize:   size,
+		windowStep:   step,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      reflect.SliceOf(streamer.curType),
+	}
+}
+
+// Concat 将other追加到当前stream之后，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Concat(other SliceStream) SliceStream {
+	otherStreamer, ok := other.(*SliceStreamer)
+	if !ok {
+		panic(fmt.Errorf("other must be *SliceStreamer, not %s", reflect.TypeOf(other)))
+	}
+	if streamer.curType != otherStreamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but other mapIter's type is %s", streamer.curType, otherStreamer.curType))
+	}
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      streamer.curType,
+		dataGetter: &concatGetter{
+			first:  streamer,
+			second: otherStreamer,
+		},
+	}
+}
+
+// Cache 缓存当前stream的结果，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Cache() SliceStream {
+	return &SliceStreamer{
+		lastStreamer: nil,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		offset:       0,
+		limit:        0,
+		curType:      streamer.curType,
+		dataGetter: &cacheGetter{
+			streamer: streamer,
+		},
+	}
+}
+
+// Limit 取前n条记录，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Limit(n int) SliceStream {
+	if n <= 0 {
+		panic(fmt.Errorf("limit rows can't less than or equal 0, but your args is %d", n))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		limit:        n,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		curType:      streamer.curType,
+	}
+}
+
+// Offset 跳过前n条记录，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) Offset(n int) SliceStream {
+	if n <= 0 {
+		panic(fmt.Errorf("offset rows can't less than or equal 0, but your args is %d", n))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		limit:        streamer.limit,
+		offset:       n,
+		skipLast:     streamer.skipLast,
+		curType:      streamer.curType,
+	}
+}
+
+// Skip 和Offset相同
+func (streamer *SliceStreamer) Skip(n int) SliceStream {
+	return streamer.Offset(n)
+}
+
+// SkipLast 去掉最后n条记录，惰性操作，只在执行了终结操作时起作用
+func (streamer *SliceStreamer) SkipLast(n int) SliceStream {
+	if n < 0 {
+		panic(fmt.Errorf("skip last rows can't less than 0, but your args is %d", n))
+	}
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		sortFunc:     nil,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     n,
+		curType:      streamer.curType,
+	}
+}
+
+// Sorted 稳定排序
+func (streamer *SliceStreamer) Sorted(sorter interface{}) SliceStream {
+	fv := reflect.ValueOf(sorter)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("sorter must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("sorter's args number must equals 2, not %d", ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	ip2 := ft.In(1)
+	if ip1 != ip2 {
+		panic(fmt.Errorf("sorter: first param type (%s) is different with second param type (%s)", ip1, ip2))
+	}
+
+	if ip1 != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but sorter's args type is %s", streamer.curType, ip1))
+	}
+
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("sorter's output number must equals 1, not %d", ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if op1.Kind() != reflect.Bool {
+		panic(fmt.Errorf("sorter's return-val type should be bool, not %s", op1))
+	}
+
+	return &SliceStreamer{
+		lastStreamer: streamer,
+		parallel:     streamer.parallel,
+		workStealing: streamer.workStealing,
+		pool:         streamer.pool,
+		filterFunc:   nil,
+		mapFunc:      nil,
+		limit:        streamer.limit,
+		offset:       streamer.offset,
+		skipLast:     streamer.skipLast,
+		sortFunc:     &fv,
+		curType:      streamer.curType,
+	}
+}
+
+// Foreach 遍历streamer中的每个元素
+func (streamer *SliceStreamer) Foreach(foreachOps ...interface{}) {
+	fvs := []reflect.Value{}
+	for i := 0; i < len(foreachOps); i++ {
+		foreachOp := foreachOps[i]
+		fv := reflect.ValueOf(foreachOp)
+		if fv.Kind() != reflect.Func {
+			panic(fmt.Errorf("foreachOp must be a function, not %s", fv.Kind()))
+		}
+		ft := fv.Type()
+		if ft.NumIn() != 1 {
+			panic(fmt.Errorf("foreachOp's args number must equals 1, not %d", ft.NumIn()))
+		}
+
+		ip1 := ft.In(0)
+		if streamer.curType != ip1 {
+			panic(fmt.Errorf("upstream mapIter's type is %s, but foreachOp's args type is %s", streamer.curType, ip1))
+		}
+
+		if ft.NumOut() != 0 {
+			panic(fmt.Errorf("foreachOp's output number must equals 0, not %d", ft.NumOut()))
+		}
+		fvs = append(fvs, fv)
+	}
+
+	result := streamer.scan()
+	for i := 0; i < len(result); i++ {
+		for j := 0; j < len(fvs); j++ {
+			_ = call(fvs[j], result[i])
+		}
+	}
+}
+
+// ForeachIndexed 带下标遍历
+func (streamer *SliceStreamer) ForeachIndexed(op interface{}) {
+	fv := reflect.ValueOf(op)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("op must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("op's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if ft.In(0).Kind() != reflect.Int {
+		panic(fmt.Errorf("op's first args type must be int, not %s", ft.In(0)))
+	}
+	ip2 := ft.In(1)
+	if streamer.curType != ip2 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but op's second args type is %s", streamer.curType, ip2))
+	}
+	if ft.NumOut() != 0 {
+		panic(fmt.Errorf("op's output number must equals 0, not %d", ft.NumOut()))
+	}
+
+	result := streamer.scan()
+	for i := 0; i < len(result); i++ {
+		fv.Call([]reflect.Value{reflect.ValueOf(i), reflect.ValueOf(result[i])})
+	}
+}
+
+// Scan 将结果带出
+func (streamer *SliceStreamer) Scan(result interface{}) {
+	val := reflect.ValueOf(result)
+	rt := reflect.TypeOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		panic(errors.New("result must be slice pointer"))
+	}
+	val = val.Elem()
+	rt = rt.Elem().Elem()
+	if rt != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but Scan's args type is %s", streamer.curType, rt))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeSlice(val.Type(), 0, 0))
+	}
+	scanResult := streamer.scan()
+	fillSlice(val, scanResult)
+}
+
+// ScanEach 将结果带出，并对每个元素执行op
+func (streamer *SliceStreamer) ScanEach(op interface{}, result interface{}) {
+	c := newCaller(streamer.checkOp("op", op))
+	val := streamer.checkResultSlice("ScanEach", result)
+	scanResult := streamer.scan()
+	resizeSlice(val, len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		val.Index(i).Set(valueOf(scanResult[i], streamer.curType))
+		_ = c.call1(scanResult[i])
+	}
+}
+
+// ScanPage 分页将结果带出，并返回总数
+func (streamer *SliceStreamer) ScanPage(offset, limit int, result interface{}) (total int) {
+	val := streamer.checkResultSlice("ScanPage", result)
+	scanResult := streamer.scan()
+	total = len(scanResult)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	fillSlice(val, scanResult[offset:end])
+	return total
+}
+
+// ScanCollectErrors 将结果带出，并收集mapper返回的所有error
+func (streamer *SliceStreamer) ScanCollectErrors(result interface{}, errs interface{}) {
+	val := reflect.ValueOf(result)
+	rt := reflect.TypeOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		panic(errors.New("result must be slice pointer"))
+	}
+	val = val.Elem()
+	rt = rt.Elem().Elem()
+	if rt != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but ScanCollectErrors's args type is %s", streamer.curType, rt))
+	}
+	errsPtr, ok := errs.(*[]error)
+	if !ok {
+		panic(fmt.Errorf("errs must be *[]error, not %s", reflect.TypeOf(errs)))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeSlice(val.Type(), 0, 0))
+	}
+	scanResult, scanErrs := streamer.scanCollectErrors()
+	// 先清空已有数据
+	val.SetLen(0)
+	for i := 0; i < len(scanResult); i++ {
+		val.Set(reflect.Append(val, valueOf(scanResult[i], streamer.curType)))
+	}
+	*errsPtr = append((*errsPtr)[:0], scanErrs...)
+}
+
+// TryScan 将结果带出，panic时返回error
+func (streamer *SliceStreamer) TryScan(result interface{}) (err error) {
+	defer recoverError(&err)
+	streamer.Scan(result)
+	return nil
+}
+
+// TryCount 获取元素数，panic时返回error
+func (streamer *SliceStreamer) TryCount() (count int, err error) {
+	defer recoverError(&err)
+	return streamer.Count(), nil
+}
+
+// TryForeach 遍历所有结果，panic时返回error
+func (streamer *SliceStreamer) TryForeach(foreachOps ...interface{}) (err error) {
+	defer recoverError(&err)
+	streamer.Foreach(foreachOps...)
+	return nil
+}
+
+// Try 执行op，并将op中的panic转换为error返回
+// 构建stream时参数类型不匹配等错误会立刻panic，可以将构建和终结操作一起放在op中执行，
+// 例如使用动态构建的回调函数时，避免一个错误的函数使整个进程崩溃
+func Try(op func()) (err error) {
+	defer recoverError(&err)
+	op()
+	return nil
+}
+
+// ScanAndRelease 将结果带出，并释放源数据
+func (streamer *SliceStreamer) ScanAndRelease(result interface{}) {
+	streamer.Scan(result)
+	streamerList := streamer.chain()
+	streamerList[len(streamerList)-1].dataGetter.release()
+}
+
+// ScanAs 转换类型并将结果带出
+func (streamer *SliceStreamer) ScanAs(converter interface{}, result interface{}) {
+	fv := reflect.ValueOf(converter)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("converter must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("converter's args number must equals 1, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but converter's args type is %s", streamer.curType, ip1))
+	}
+
+	if ft.NumOut() == 2 {
+		if ft.Out(1).Kind() != reflect.Bool {
+			panic(fmt.Errorf("converter's second return-val type should be bool, not %s", ft.Out(1)))
+		}
+	} else if ft.NumOut() != 1 {
+		panic(fmt.Errorf("converter's output number must equals 1 or 2, not %d", ft.NumOut()))
+	}
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		panic(errors.New("result must be slice pointer"))
+	}
+	val = val.Elem()
+	if val.Type().Elem() != ft.Out(0) {
+		panic(fmt.Errorf("converter's return-value type is %s, but ScanAs's args type is %s", ft.Out(0), val.Type().Elem()))
+	}
+
+	scanResult := streamer.scan()
+	newVal := reflect.MakeSlice(val.Type(), 0, len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		op := call(fv, scanResult[i])
+		if len(op) == 2 && !op[1].Bool() {
+			continue
+		}
+		newVal = reflect.Append(newVal, op[0])
+	}
+	val.Set(newVal)
+}
+
+// ToChannel 将结果发送到ch
+func (streamer *SliceStreamer) ToChannel(ch interface{}) {
+	val := reflect.ValueOf(ch)
+	if val.Kind() != reflect.Chan {
+		panic(fmt.Errorf("ch must be channel, not %s", val.Kind()))
+	}
+	if val.Type().ChanDir()&reflect.SendDir == 0 {
+		panic(fmt.Errorf("ch must be send-capable, but %s is not", val.Type()))
+	}
+	if val.Type().Elem() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but ToChannel's args type is %s", streamer.curType, val.Type().Elem()))
+	}
+	send := func(elem interface{}) bool {
+		val.Send(valueOf(elem, streamer.curType))
+		return true
+	}
+	if streamer.iterate(send) {
+		return
+	}
+	scanResult := streamer.scan()
+	for i := 0; i < len(scanResult); i++ {
+		send(scanResult[i])
+	}
+}
+
+// ToChannelClose 将结果发送到ch，并关闭ch
+func (streamer *SliceStreamer) ToChannelClose(ch interface{}) {
+	streamer.ToChannel(ch)
+	reflect.ValueOf(ch).Close()
+}
+
+// ToJSON 将结果序列化为JSON数组
+func (streamer *SliceStreamer) ToJSON() ([]byte, error) {
+	scanResult := streamer.scan()
+	val := reflect.MakeSlice(reflect.SliceOf(streamer.curType), len(scanResult), len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		val.Index(i).Set(valueOf(scanResult[i], streamer.curType))
+	}
+	return json.Marshal(val.Interface())
+}
+
+// BatchChannel 通过channel逐批发送结果
+func (streamer *SliceStreamer) BatchChannel(size int) <-chan interface{} {
+	if size <= 0 {
+		panic(fmt.Errorf("batch size can't less than or equal 0, but your args is %d", size))
+	}
+	batchType := reflect.SliceOf(streamer.curType)
+	ch := make(chan interface{}, 1)
+	// 需要全部数据的链直接在调用者的goroutine中执行，执行过程中的panic可以被调用者的Try捕获
+	var scanResult []interface{}
+	lazy := streamer.canIterate()
+	if !lazy {
+		scanResult = streamer.scan()
+	}
+	go func() {
+		defer close(ch)
+		// 逐个元素执行时panic发生在发送的goroutine中，作为最后一个元素交给消费者
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- newPanicError(r)
+			}
+		}()
+		pending := make([]interface{}, 0, size)
+		flush := func() {
+			batch := reflect.MakeSlice(batchType, len(pending), len(pending))
+			for i := 0; i < len(pending); i++ {
+				batch.Index(i).Set(valueOf(pending[i], streamer.curType))
+			}
+			ch <- batch.Interface()
+			pending = pending[:0]
+		}
+		add := func(elem interface{}) bool {
+			pending = append(pending, elem)
+			if len(pending) == size {
+				flush()
+			}
+			return true
+		}
+		if lazy {
+			streamer.iterate(add)
+		} else {
+			for i := 0; i < len(scanResult); i++ {
+				add(scanResult[i])
+			}
+		}
+		if len(pending) > 0 {
+			flush()
+		}
+	}()
+	return ch
+}
+
+// ScanN 将结果写入result已有的位置，返回写入的元素数
+func (streamer *SliceStreamer) ScanN(result interface{}) int {
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || (val.Elem().Kind() != reflect.Slice && val.Elem().Kind() != reflect.Array) {
+		panic(errors.New("result must be slice pointer or array pointer"))
+	}
+	val = val.Elem()
+	if val.Type().Elem() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but ScanN's args type is %s", streamer.curType, val.Type().Elem()))
+	}
+	scanResult := streamer.scan()
+	n := val.Len()
+	if len(scanResult) < n {
+		n = len(scanResult)
+	}
+	for i := 0; i < n; i++ {
+		val.Index(i).Set(valueOf(scanResult[i], streamer.curType))
+	}
+	return n
+}
+
+// ScanCopy 将结果深拷贝后带出
+func (streamer *SliceStreamer) ScanCopy(result interface{}) {
+	val := streamer.checkResultSlice("ScanCopy", result)
+	scanResult := streamer.scan()
+	newVal := reflect.MakeSlice(val.Type(), 0, len(scanResult))
+	visited := map[uintptr]reflect.Value{}
+	for i := 0; i < len(scanResult); i++ {
+		newVal = reflect.Append(newVal, deepCopy(valueOf(scanResult[i], streamer.curType), visited))
+	}
+	val.Set(newVal)
+}
+
+// Count 计数
+func (streamer *SliceStreamer) Count() int {
+	result := streamer.scan()
+	return len(result)
+}
+
+// IsSorted 判断结果是否按less非降序排列
+func (streamer *SliceStreamer) IsSorted(less interface{}) bool {
+	fv := streamer.checkLess("less", less)
+	scanResult := streamer.scan()
+	for i := 1; i < len(scanResult); i++ {
+		if call(fv, scanResult[i], scanResult[i-1])[0].Bool() {
+			return false
+		}
+	}
+	return true
+}
+
+// GroupBy 根据getKey函数获取key，并将group by结果作为一个result map带回
+func (streamer *SliceStreamer) GroupBy(keyer interface{}, result interface{}) {
+	if keyer == nil {
+		panic(errors.New("keyer func can't be nil"))
+	}
+	fv := reflect.ValueOf(keyer)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("keyer must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("keyer's args number must equals 1, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but keyer's args type is %s", streamer.curType, ip1))
+	}
+
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("keyer's output number must equals 1, not %d", ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	val := reflect.ValueOf(result)
+	rt := reflect.TypeOf(result)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+		rt = rt.Elem()
+	}
+	if val.Kind() != reflect.Map {
+		panic(fmt.Errorf("GroupBy result must be map or map pointer, not %s", val.Kind()))
+	}
+	if rt.Key() != op1 {
+		panic(fmt.Errorf("keyer's return-value type is %s, but GroupBy result's key type is %s", op1, rt.Key()))
+	}
+	if rt.Elem().Elem() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but GroupBy result's value type is %s", streamer.curType, rt.Elem().Elem()))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+
+	scanResult := streamer.scan()
+	streamer.groupBy(fv, scanResult, &val)
+}
+
+// CountBy 根据keyer统计每个key的元素数，结果由result带出
+func (streamer *SliceStreamer) CountBy(keyer interface{}, result interface{}) {
+	fv := streamer.checkKeyer("keyer", keyer)
+	val := reflect.ValueOf(result)
+	rt := reflect.TypeOf(result)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+		rt = rt.Elem()
+	}
+	if val.Kind() != reflect.Map {
+		panic(fmt.Errorf("CountBy result must be map or map pointer, not %s", val.Kind()))
+	}
+	if rt.Key() != fv.Type().Out(0) {
+		panic(fmt.Errorf("keyer's return-value type is %s, but CountBy result's key type is %s", fv.Type().Out(0), rt.Key()))
+	}
+	if rt.Elem().Kind() != reflect.Int {
+		panic(fmt.Errorf("CountBy result's value type must be int, not %s", rt.Elem()))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+
+	scanResult := streamer.scan()
+	streamer.countBy(fv, scanResult, &val)
+}
+
+// GroupByReduce 分组并将每个分组聚合成一个值，结果由result带出
+func (streamer *SliceStreamer) GroupByReduce(keyer, accumulator interface{}, result interface{}) {
+	keyFv := streamer.checkKeyer("keyer", keyer)
+	accFv := streamer.checkAccumulator("accumulator", accumulator)
+	val := checkResultMap("GroupByReduce", result, keyFv.Type().Out(0), streamer.curType)
+	scanResult := streamer.scan()
+	// 以interface{}为key暂存聚合值，避免每个元素都通过反射读写result
+	accs := map[interface{}]reflect.Value{}
+	keys := []reflect.Value{}
+	for i := 0; i < len(scanResult); i++ {
+		key := call(keyFv, scanResult[i])[0]
+		acc, ok := accs[key.Interface()]
+		if !ok {
+			accs[key.Interface()] = valueOf(scanResult[i], streamer.curType)
+			keys = append(keys, key)
+			continue
+		}
+		accs[key.Interface()] = call(accFv, acc.Interface(), scanResult[i])[0]
+	}
+	for i := 0; i < len(keys); i++ {
+		val.SetMapIndex(keys[i], accs[keys[i].Interface()])
+	}
+}
+
+// ToMap 根据getKey函数获取key，并将to map结果作为一个result map带回
+func (streamer *SliceStreamer) ToMap(keyer interface{}, result interface{}) {
+	if keyer == nil {
+		panic(errors.New("keyer func can't be nil"))
+	}
+	fv := reflect.ValueOf(keyer)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("keyer must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("keyer's args number must equals 1, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but keyer's args type is %s", streamer.curType, ip1))
+	}
+
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("keyer's output number must equals 1, not %d", ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	val := reflect.ValueOf(result)
+	rt := reflect.TypeOf(result)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+		rt = rt.Elem()
+	}
+	if val.Kind() != reflect.Map {
+		panic(fmt.Errorf("GroupBy result must be map or map pointer, not %s", val.Kind()))
+	}
+	if rt.Key() != op1 {
+		panic(fmt.Errorf("keyer's return-value type is %s, but GroupBy result's key type is %s", op1, rt.Key()))
+	}
+	if rt.Elem() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but GroupBy result's value type is %s", streamer.curType, rt.Elem()))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+
+	scanResult := streamer.scan()
+	streamer.toMap(fv, scanResult, &val)
+}
+
+// Associate 根据keyer和valuer构建map，结果由result带出
+func (streamer *SliceStreamer) Associate(keyer, valuer interface{}, result interface{}) {
+	keyFv := streamer.checkKeyer("keyer", keyer)
+	valueFv := streamer.checkSelector("valuer", valuer)
+	val := checkResultMap("Associate", result, keyFv.Type().Out(0), valueFv.Type().Out(0))
+	scanResult := streamer.scan()
+	for i := 0; i < len(scanResult); i++ {
+		val.SetMapIndex(call(keyFv, scanResult[i])[0], call(valueFv, scanResult[i])[0])
+	}
+}
+
+// Report 分组并聚合成按key升序排列的报表行，结果由result带出
+func (streamer *SliceStreamer) Report(keyer interface{}, aggregators map[string]interface{}, result interface{}) {
+	fv := streamer.checkKeyer("keyer", keyer)
+	if !isOrdered(fv.Type().Out(0)) {
+		panic(fmt.Errorf("keyer's return-val type should be integer, float or string, but %s is not", fv.Type().Out(0)))
+	}
+	itemsType := reflect.SliceOf(streamer.curType)
+	aggregatorVals := map[string]reflect.Value{}
+	for name, aggregator := range aggregators {
+		av := reflect.ValueOf(aggregator)
+		if av.Kind() != reflect.Func {
+			panic(fmt.Errorf("aggregator %s must be a function, not %s", name, av.Kind()))
+		}
+		at := av.Type()
+		if at.NumIn() != 1 || at.In(0) != itemsType {
+			panic(fmt.Errorf("aggregator %s's args must be a single %s", name, itemsType))
+		}
+		if at.NumOut() != 1 {
+			panic(fmt.Errorf("aggregator %s's output number must equals 1, not %d", name, at.NumOut()))
+		}
+		aggregatorVals[name] = av
+	}
+	rows, ok := result.(*[]ReportRow)
+	if !ok {
+		panic(fmt.Errorf("Report result must be *[]ReportRow, not %s", reflect.TypeOf(result)))
+	}
+
+	groups := groupToStream(fv, streamer.scan())
+	sort.Slice(groups, func(i, j int) bool {
+		return lessOrdered(reflect.ValueOf(groups[i].(Group).Key), reflect.ValueOf(groups[j].(Group).Key))
+	})
+	newRows := make([]ReportRow, 0, len(groups))
+	for i := 0; i < len(groups); i++ {
+		group := groups[i].(Group)
+		items := reflect.MakeSlice(itemsType, len(group.Items), len(group.Items))
+		for j := 0; j < len(group.Items); j++ {
+			items.Index(j).Set(reflect.ValueOf(group.Items[j]))
+		}
+		row := ReportRow{Key: group.Key, Values: make(map[string]interface{}, len(aggregatorVals))}
+		for name, av := range aggregatorVals {
+			row.Values[name] = av.Call([]reflect.Value{items})[0].Interface()
+		}
+		newRows = append(newRows, row)
+	}
+	*rows = newRows
+}
+
+// ToSet 将结果转换成set，结果由result带出
+func (streamer *SliceStreamer) ToSet(result interface{}) {
+	if !streamer.curType.Comparable() {
+		panic(fmt.Errorf("ToSet: upstream mapIter's type should be comparable, but %s is not", streamer.curType))
+	}
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Map {
+		panic(errors.New("result must be map pointer"))
+	}
+	val = val.Elem()
+	rt := val.Type()
+	if rt.Key() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but ToSet result's key type is %s", streamer.curType, rt.Key()))
+	}
+	if rt.Elem() != reflect.TypeOf(struct{}{}) {
+		panic(fmt.Errorf("ToSet result's value type must be struct{}, not %s", rt.Elem()))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(rt))
+	}
+
+	scanResult := streamer.scan()
+	empty := reflect.ValueOf(struct{}{})
+	for i := 0; i < len(scanResult); i++ {
+		val.SetMapIndex(valueOf(scanResult[i], streamer.curType), empty)
+	}
+}
+
+// ToIndexMap 以下标为key，将结果作为一个result map带回
+func (streamer *SliceStreamer) ToIndexMap(result interface{}) {
+	val := reflect.ValueOf(result)
 	rt := reflect.TypeOf(result)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+		rt = rt.Elem()
+	}
+	if val.Kind() != reflect.Map {
+		panic(fmt.Errorf("ToIndexMap result must be map or map pointer, not %s", val.Kind()))
+	}
+	if rt.Key().Kind() != reflect.Int {
+		panic(fmt.Errorf("ToIndexMap result's key type must be int, not %s", rt.Key()))
+	}
+	if rt.Elem() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but ToIndexMap result's value type is %s", streamer.curType, rt.Elem()))
+	}
+	// nil map init
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+
+	scanResult := streamer.scan()
+	for i := 0; i < len(scanResult); i++ {
+		val.SetMapIndex(reflect.ValueOf(i).Convert(rt.Key()), valueOf(scanResult[i], streamer.curType))
+	}
+}
+
+// PercentByKey 计算每个key的元素数占总数的比例，结果由result带出
+func (streamer *SliceStreamer) PercentByKey(keyer interface{}, result interface{}) {
+	fv := streamer.checkKeyer("keyer", keyer)
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Map {
+		panic(errors.New("result must be map pointer"))
+	}
+	val = val.Elem()
+	rt := val.Type()
+	if rt.Key() != fv.Type().Out(0) {
+		panic(fmt.Errorf("keyer's return-value type is %s, but PercentByKey result's key type is %s", fv.Type().Out(0), rt.Key()))
+	}
+	if rt.Elem().Kind() != reflect.Float64 {
+		panic(fmt.Errorf("PercentByKey result's value type must be float64, not %s", rt.Elem()))
+	}
+
+	scanResult := streamer.scan()
+	keys := []reflect.Value{}
+	counts := map[interface{}]int{}
+	for i := 0; i < len(scanResult); i++ {
+		key := call(fv, scanResult[i])[0]
+		if _, ok := counts[key.Interface()]; !ok {
+			keys = append(keys, key)
+		}
+		counts[key.Interface()]++
+	}
+	newVal := reflect.MakeMapWithSize(rt, len(keys))
+	for _, key := range keys {
+		percent := float64(counts[key.Interface()]) / float64(len(scanResult))
+		newVal.SetMapIndex(key, reflect.ValueOf(percent).Convert(rt.Elem()))
+	}
+	val.Set(newVal)
+}
+
+// FindDuplicates 找出key重复的所有元素，结果由result带出
+func (streamer *SliceStreamer) FindDuplicates(keyer interface{}, result interface{}) {
+	fv := streamer.checkKeyer("keyer", keyer)
+	val := streamer.checkResultSlice("FindDuplicates", result)
+	scanResult := streamer.scan()
+	keys := make([]interface{}, len(scanResult))
+	counts := map[interface{}]int{}
+	for i := 0; i < len(scanResult); i++ {
+		keys[i] = call(fv, scanResult[i])[0].Interface()
+		counts[keys[i]]++
+	}
+	duplicates := []interface{}{}
+	for i := 0; i < len(scanResult); i++ {
+		if counts[keys[i]] > 1 {
+			duplicates = append(duplicates, scanResult[i])
+		}
+	}
+	setSlice(val, duplicates)
+}
+
+// SymmetricDifference 返回只在a或只在b中出现的元素（按keyer的返回值判断），先a后b，保持原有顺序
+// a和b的数据类型需要一致，keyer参数应为 func (item T) K，result参数应为*[]T
+func SymmetricDifference(a, b SliceStream, keyer interface{}, result interface{}) {
+	aStreamer, ok := a.(*SliceStreamer)
+	if !ok {
+		panic(fmt.Errorf("a must be *SliceStreamer, not %s", reflect.TypeOf(a)))
+	}
+	bStreamer, ok := b.(*SliceStreamer)
+	if !ok {
+		panic(fmt.Errorf("b must be *SliceStreamer, not %s", reflect.TypeOf(b)))
+	}
+	if aStreamer.curType != bStreamer.curType {
+		panic(fmt.Errorf("a's type is %s, but b's type is %s", aStreamer.curType, bStreamer.curType))
+	}
+	fv := aStreamer.checkKeyer("keyer", keyer)
+	val := aStreamer.checkResultSlice("SymmetricDifference", result)
+	aResult := aStreamer.scan()
+	bResult := bStreamer.scan()
+	aKeys := make([]interface{}, len(aResult))
+	aSet := make(map[interface{}]struct{}, len(aResult))
+	for i := 0; i < len(aResult); i++ {
+		aKeys[i] = call(fv, aResult[i])[0].Interface()
+		aSet[aKeys[i]] = struct{}{}
+	}
+	bKeys := make([]interface{}, len(bResult))
+	bSet := make(map[interface{}]struct{}, len(bResult))
+	for i := 0; i < len(bResult); i++ {
+		bKeys[i] = call(fv, bResult[i])[0].Interface()
+		bSet[bKeys[i]] = struct{}{}
+	}
+	difference := []interface{}{}
+	for i := 0; i < len(aResult); i++ {
+		if _, ok := bSet[aKeys[i]]; !ok {
+			difference = append(difference, aResult[i])
+		}
+	}
+	for i := 0; i < len(bResult); i++ {
+		if _, ok := aSet[bKeys[i]]; !ok {
+			difference = append(difference, bResult[i])
+		}
+	}
+	setSlice(val, difference)
+}
+
+// AssertUnique 校验key的唯一性，返回第一个冲突
+func (streamer *SliceStreamer) AssertUnique(keyer interface{}) error {
+	fv := streamer.checkKeyer("keyer", keyer)
+	scanResult := streamer.scan()
+	seen := make(map[interface{}]int, len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		key := call(fv, scanResult[i])[0].Interface()
+		if j, ok := seen[key]; ok {
+			return fmt.Errorf("duplicate key %v: %v and %v", key, scanResult[j], scanResult[i])
+		}
+		seen[key] = i
+	}
+	return nil
+}
+
+// Reduce 根据accumulator两两聚合，结果由result带出
+func (streamer *SliceStreamer) Reduce(accumulator interface{}, result interface{}) {
+	fv := reflect.ValueOf(accumulator)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("accumulator must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("accumulator's args number must equals 2, not %d", ft.NumIn()))
+	}
+
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's first args type is %s", streamer.curType, ip1))
+	}
+
+	ip2 := ft.In(1)
+	if streamer.curType != ip2 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's second args type is %s", streamer.curType, ip2))
+	}
+
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("accumulator's output number must equals 1, not %d", ft.NumOut()))
+	}
+
+	op1 := ft.Out(0)
+	if streamer.curType != op1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's return-value type is %s", streamer.curType, op1))
+	}
+
+	iv := reflect.ValueOf(result)
+	if iv.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("result must be a %s ptr", streamer.curType))
+	}
+
+	if iv.Elem().Type() != streamer.curType {
+		panic(fmt.Errorf("accumulator must be a %s, not %s", streamer.curType, iv.Elem().Type()))
+	}
+	streamer.reduce(fv, iv.Elem())
+}
+
+// Fold 从identity开始聚合所有元素，结果由result带出
+func (streamer *SliceStreamer) Fold(identity interface{}, accumulator interface{}, result interface{}) {
+	fv := reflect.ValueOf(accumulator)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("accumulator must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("accumulator's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if streamer.curType != ft.In(0) {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's first args type is %s", streamer.curType, ft.In(0)))
+	}
+	if streamer.curType != ft.In(1) {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's second args type is %s", streamer.curType, ft.In(1)))
+	}
+	if ft.NumOut() != 1 || ft.Out(0) != streamer.curType {
+		panic(fmt.Errorf("accumulator's output must be a single %s", streamer.curType))
+	}
+	identityVal := streamer.checkResultPtr("Fold", identity)
+	val := streamer.checkResultPtr("Fold", result)
+
+	data := streamer.scan()
+	acc := identityVal
+	for i := 0; i < len(data); i++ {
+		acc = fv.Call([]reflect.Value{acc, valueOf(data[i], streamer.curType)})[0]
+	}
+	val.Set(acc)
+}
+
+// Collect 通过supplier创建容器并收集所有元素，结果由result带出
+func (streamer *SliceStreamer) Collect(supplier, accumulator interface{}, result interface{}) {
+	sv := reflect.ValueOf(supplier)
+	if sv.Kind() != reflect.Func {
+		panic(fmt.Errorf("supplier must be a function, not %s", sv.Kind()))
+	}
+	st := sv.Type()
+	if st.NumIn() != 0 {
+		panic(fmt.Errorf("supplier's args number must equals 0, not %d", st.NumIn()))
+	}
+	if st.NumOut() != 1 {
+		panic(fmt.Errorf("supplier's output number must equals 1, not %d", st.NumOut()))
+	}
+	accType := st.Out(0)
+	fv := reflect.ValueOf(accumulator)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("accumulator must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("accumulator's args number must equals 2, not %d", ft.NumIn()))
+	}
+	if ft.In(0) != accType {
+		panic(fmt.Errorf("supplier's return-value type is %s, but accumulator's first args type is %s", accType, ft.In(0)))
+	}
+	if streamer.curType != ft.In(1) {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's second args type is %s", streamer.curType, ft.In(1)))
+	}
+	if ft.NumOut() != 0 {
+		panic(fmt.Errorf("accumulator's output number must equals 0, not %d", ft.NumOut()))
+	}
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("result must be a %s ptr", accType))
+	}
+	if val.Elem().Type() != accType {
+		panic(fmt.Errorf("supplier's return-value type is %s, but Collect's args type is %s", accType, val.Elem().Type()))
+	}
+
+	data := streamer.scan()
+	acc := sv.Call(nil)[0]
+	for i := 0; i < len(data); i++ {
+		fv.Call([]reflect.Value{acc, valueOf(data[i], streamer.curType)})
+	}
+	val.Elem().Set(acc)
+}
+
+// TumblingAggregate 滚动窗口聚合，结果由result带出
+func (streamer *SliceStreamer) TumblingAggregate(size int, accumulator interface{}, init interface{}, result interface{}) {
+	if size <= 0 {
+		panic(fmt.Errorf("window size can't less than or equal 0, but your args is %d", size))
+	}
+	fv := reflect.ValueOf(accumulator)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("accumulator must be a function, not %s", fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("accumulator's args number must equals 2, not %d", ft.NumIn()))
+	}
+	accType := ft.In(0)
+	ip2 := ft.In(1)
+	if streamer.curType != ip2 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's second args type is %s", streamer.curType, ip2))
+	}
+	if ft.NumOut() != 1 || ft.Out(0) != accType {
+		panic(fmt.Errorf("accumulator's output must be a single %s", accType))
+	}
+	initVal := reflect.ValueOf(init)
+	if !initVal.IsValid() || initVal.Type() != accType {
+		panic(fmt.Errorf("init must be a %s, not %s", accType, reflect.TypeOf(init)))
+	}
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		panic(errors.New("result must be slice pointer"))
+	}
+	val = val.Elem()
+	if val.Type().Elem() != accType {
+		panic(fmt.Errorf("accumulator's return-value type is %s, but TumblingAggregate result's elem type is %s", accType, val.Type().Elem()))
+	}
+
+	scanResult := streamer.scan()
+	aggregates := reflect.MakeSlice(val.Type(), 0, (len(scanResult)+size-1)/size)
+	for start := 0; start < len(scanResult); start += size {
+		end := start + size
+		if end > len(scanResult) {
+			end = len(scanResult)
+		}
+		acc := initVal
+		for i := start; i < end; i++ {
+			acc = fv.Call([]reflect.Value{acc, valueOf(scanResult[i], streamer.curType)})[0]
+		}
+		aggregates = reflect.Append(aggregates, acc)
+	}
+	val.Set(aggregates)
+}
+
+// Shard 将结果切分成n个大小相近的连续分片，结果由result带出
+func (streamer *SliceStreamer) Shard(n int, result interface{}) {
+	if n <= 0 {
+		panic(fmt.Errorf("shard number can't less than or equal 0, but your args is %d", n))
+	}
+	val := reflect.ValueOf(result)
 	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
 		panic(errors.New("result must be slice pointer"))
 	}
 	val = val.Elem()
-	rt = rt.Elem().Elem()
-	if rt != streamer.curType {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but Scan's args type is %s", streamer.curType, rt))
-	}
-	// nil map init
-	if val.IsNil() {
-		val.Set(reflect.MakeSlice(val.Type(), 0, 0))
+	if val.Type().Elem() != reflect.SliceOf(streamer.curType) {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but Shard result's elem type is %s", streamer.curType, val.Type().Elem()))
 	}
+
 	scanResult := streamer.scan()
-	// 先清空已有数据
-	val.SetLen(0)
-	for i := 0; i < len(scanResult); i++ {
-		val.Set(reflect.Append(val, reflect.ValueOf(scanResult[i])))
+	shards := reflect.MakeSlice(val.Type(), n, n)
+	batch := len(scanResult) / n
+	remain := len(scanResult) % n
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + batch
+		if i < remain {
+			end++
+		}
+		shard := reflect.MakeSlice(val.Type().Elem(), 0, end-start)
+		for j := start; j < end; j++ {
+			shard = reflect.Append(shard, valueOf(scanResult[j], streamer.curType))
+		}
+		shards.Index(i).Set(shard)
+		start = end
 	}
+	val.Set(shards)
 }
 
-// Count 计数
-func (streamer *SliceStreamer) Count() int {
-	result := streamer.scan()
-	return len(result)
+// Sum 求和，结果由result带出
+func (streamer *SliceStreamer) Sum(result interface{}) {
+	if !isNumeric(streamer.curType) {
+		panic(fmt.Errorf("Sum: upstream mapIter's type %s is not numeric", streamer.curType))
+	}
+	iv := reflect.ValueOf(result)
+	if iv.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("result must be a %s ptr", streamer.curType))
+	}
+	if iv.Elem().Type() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but Sum's args type is %s", streamer.curType, iv.Elem().Type()))
+	}
+	scanResult := streamer.scan()
+	sum := reflect.New(streamer.curType).Elem()
+	for i := 0; i < len(scanResult); i++ {
+		addNumeric(sum, reflect.ValueOf(scanResult[i]))
+	}
+	iv.Elem().Set(sum)
 }
 
-// GroupBy 根据getKey函数获取key，并将group by结果作为一个result map带回
-func (streamer *SliceStreamer) GroupBy(keyer interface{}, result interface{}) {
-	if keyer == nil {
-		panic(errors.New("keyer func can't be nil"))
+// Average 求平均值，结果由result带出
+func (streamer *SliceStreamer) Average(result interface{}) {
+	if !isNumeric(streamer.curType) {
+		panic(fmt.Errorf("Average: upstream mapIter's type %s is not numeric", streamer.curType))
 	}
-	fv := reflect.ValueOf(keyer)
-	if fv.Kind() != reflect.Func {
-		panic(fmt.Errorf("keyer must be a function, not %s", fv.Kind()))
+	avg, ok := result.(*float64)
+	if !ok {
+		panic(fmt.Errorf("Average result must be *float64, not %s", reflect.TypeOf(result)))
 	}
-	ft := fv.Type()
-	if ft.NumIn() != 1 {
-		panic(fmt.Errorf("keyer's args number must equals 1, not %d", ft.NumIn()))
+	scanResult := streamer.scan()
+	if len(scanResult) == 0 {
+		*avg = 0
+		return
 	}
-
-	ip1 := ft.In(0)
-	if streamer.curType != ip1 {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but keyer's args type is %s", streamer.curType, ip1))
+	sum := 0.0
+	for i := 0; i < len(scanResult); i++ {
+		sum += toFloat64(reflect.ValueOf(scanResult[i]))
 	}
+	*avg = sum / float64(len(scanResult))
+}
 
-	if ft.NumOut() != 1 {
-		panic(fmt.Errorf("keyer's output number must equals 1, not %d", ft.NumOut()))
+// SumBy 对提取的数值求和，结果由result带出
+func (streamer *SliceStreamer) SumBy(extractor interface{}, result interface{}) {
+	fv := streamer.checkNumericSelector("extractor", extractor)
+	sumType := fv.Type().Out(0)
+	iv := reflect.ValueOf(result)
+	if iv.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("result must be a %s ptr", sumType))
 	}
-	op1 := ft.Out(0)
-	val := reflect.ValueOf(result)
-	rt := reflect.TypeOf(result)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-		rt = rt.Elem()
+	if iv.Elem().Type() != sumType {
+		panic(fmt.Errorf("extractor's return-val type is %s, but SumBy's args type is %s", sumType, iv.Elem().Type()))
 	}
-	if val.Kind() != reflect.Map {
-		panic(fmt.Errorf("GroupBy result must be map or map pointer, not %s", val.Kind()))
+	scanResult := streamer.scan()
+	sum := reflect.New(sumType).Elem()
+	for i := 0; i < len(scanResult); i++ {
+		addNumeric(sum, call(fv, scanResult[i])[0])
 	}
-	if rt.Key() != op1 {
-		panic(fmt.Errorf("keyer's return-value type is %s, but GroupBy result's key type is %s", op1, rt.Key()))
+	iv.Elem().Set(sum)
+}
+
+// AverageBy 对提取的数值求平均值，结果由result带出
+func (streamer *SliceStreamer) AverageBy(extractor interface{}, result interface{}) {
+	fv := streamer.checkNumericSelector("extractor", extractor)
+	avg, ok := result.(*float64)
+	if !ok {
+		panic(fmt.Errorf("AverageBy result must be *float64, not %s", reflect.TypeOf(result)))
 	}
-	if rt.Elem().Elem() != streamer.curType {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but GroupBy result's value type is %s", streamer.curType, rt.Elem().Elem()))
+	scanResult := streamer.scan()
+	if len(scanResult) == 0 {
+		*avg = 0
+		return
 	}
-	// nil map init
-	if val.IsNil() {
-		val.Set(reflect.MakeMap(val.Type()))
+	sum := 0.0
+	for i := 0; i < len(scanResult); i++ {
+		sum += toFloat64(call(fv, scanResult[i])[0])
 	}
+	*avg = sum / float64(len(scanResult))
+}
 
+// WeightedAverage 求加权平均值
+func (streamer *SliceStreamer) WeightedAverage(valueSel, weightSel interface{}, result *float64) bool {
+	valueFv := streamer.checkNumericSelector("valueSel", valueSel)
+	weightFv := streamer.checkNumericSelector("weightSel", weightSel)
 	scanResult := streamer.scan()
-	streamer.groupBy(fv, scanResult, &val)
+	weightedSum := 0.0
+	totalWeight := 0.0
+	for i := 0; i < len(scanResult); i++ {
+		value := toFloat64(call(valueFv, scanResult[i])[0])
+		weight := toFloat64(call(weightFv, scanResult[i])[0])
+		weightedSum += value * weight
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return false
+	}
+	*result = weightedSum / totalWeight
+	return true
 }
 
-// ToMap 根据getKey函数获取key，并将to map结果作为一个result map带回
-func (streamer *SliceStreamer) ToMap(keyer interface{}, result interface{}) {
-	if keyer == nil {
-		panic(errors.New("keyer func can't be nil"))
+// Min 取最小的元素
+func (streamer *SliceStreamer) Min(less interface{}, result interface{}) bool {
+	fv := streamer.checkLess("less", less)
+	val := streamer.checkResultPtr("Min", result)
+	return streamer.extreme(fv, val, false)
+}
+
+// Max 取最大的元素
+func (streamer *SliceStreamer) Max(less interface{}, result interface{}) bool {
+	fv := streamer.checkLess("less", less)
+	val := streamer.checkResultPtr("Max", result)
+	return streamer.extreme(fv, val, true)
+}
+
+// MinBy 取key最小的元素
+func (streamer *SliceStreamer) MinBy(keyer interface{}, result interface{}) bool {
+	fv := streamer.checkOrderedKeyer("keyer", keyer)
+	val := streamer.checkResultPtr("MinBy", result)
+	return streamer.extremeBy(fv, val, false)
+}
+
+// MaxBy 取key最大的元素
+func (streamer *SliceStreamer) MaxBy(keyer interface{}, result interface{}) bool {
+	fv := streamer.checkOrderedKeyer("keyer", keyer)
+	val := streamer.checkResultPtr("MaxBy", result)
+	return streamer.extremeBy(fv, val, true)
+}
+
+// NthBy 通过快速选择获取第n小的元素
+func (streamer *SliceStreamer) NthBy(n int, less interface{}, result interface{}) bool {
+	fv := streamer.checkLess("less", less)
+	val := streamer.checkResultPtr("NthBy", result)
+	data := streamer.scan()
+	if n < 0 || n >= len(data) {
+		return false
 	}
-	fv := reflect.ValueOf(keyer)
-	if fv.Kind() != reflect.Func {
-		panic(fmt.Errorf("keyer must be a function, not %s", fv.Kind()))
+	val.Set(reflect.ValueOf(quickSelect(data, n, func(a, b interface{}) bool {
+		return call(fv, a, b)[0].Bool()
+	})))
+	return true
+}
+
+// CumulativeMax 计算累计最大值，结果由result带出
+func (streamer *SliceStreamer) CumulativeMax(less interface{}, result interface{}) {
+	fv := streamer.checkLess("less", less)
+	val := streamer.checkResultSlice("CumulativeMax", result)
+	scanResult := streamer.scan()
+	maxes := make([]interface{}, 0, len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		if i == 0 || call(fv, maxes[i-1], scanResult[i])[0].Bool() {
+			maxes = append(maxes, scanResult[i])
+		} else {
+			maxes = append(maxes, maxes[i-1])
+		}
 	}
-	ft := fv.Type()
-	if ft.NumIn() != 1 {
-		panic(fmt.Errorf("keyer's args number must equals 1, not %d", ft.NumIn()))
+	setSlice(val, maxes)
+}
+
+// Render 对每个元素执行一次模板
+func (streamer *SliceStreamer) Render(w io.Writer, tmpl *template.Template) error {
+	scanResult := streamer.scan()
+	for i := 0; i < len(scanResult); i++ {
+		if err := tmpl.Execute(w, scanResult[i]); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	ip1 := ft.In(0)
-	if streamer.curType != ip1 {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but keyer's args type is %s", streamer.curType, ip1))
+// RenderAll 将所有结果作为上下文执行一次模板
+func (streamer *SliceStreamer) RenderAll(w io.Writer, tmpl *template.Template) error {
+	scanResult := streamer.scan()
+	val := reflect.MakeSlice(reflect.SliceOf(streamer.curType), 0, len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		val = reflect.Append(val, valueOf(scanResult[i], streamer.curType))
 	}
+	return tmpl.Execute(w, val.Interface())
+}
 
-	if ft.NumOut() != 1 {
-		panic(fmt.Errorf("keyer's output number must equals 1, not %d", ft.NumOut()))
+// AnyMatch 是否存在满足pred的元素
+func (streamer *SliceStreamer) AnyMatch(pred interface{}) bool {
+	fv := streamer.checkPredicate("pred", pred)
+	matched := false
+	match := func(elem interface{}) bool {
+		matched = call(fv, elem)[0].Bool()
+		return !matched
 	}
-	op1 := ft.Out(0)
-	val := reflect.ValueOf(result)
-	rt := reflect.TypeOf(result)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-		rt = rt.Elem()
+	if streamer.iterate(match) {
+		return matched
 	}
-	if val.Kind() != reflect.Map {
-		panic(fmt.Errorf("GroupBy result must be map or map pointer, not %s", val.Kind()))
+	scanResult := streamer.scan()
+	for i := 0; i < len(scanResult) && match(scanResult[i]); i++ {
 	}
-	if rt.Key() != op1 {
-		panic(fmt.Errorf("keyer's return-value type is %s, but GroupBy result's key type is %s", op1, rt.Key()))
+	return matched
+}
+
+// AllMatch 是否所有元素都满足pred
+func (streamer *SliceStreamer) AllMatch(pred interface{}) bool {
+	fv := streamer.checkPredicate("pred", pred)
+	all := true
+	match := func(elem interface{}) bool {
+		all = call(fv, elem)[0].Bool()
+		return all
 	}
-	if rt.Elem() != streamer.curType {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but GroupBy result's value type is %s", streamer.curType, rt.Elem()))
+	if streamer.iterate(match) {
+		return all
 	}
-	// nil map init
-	if val.IsNil() {
-		val.Set(reflect.MakeMap(val.Type()))
+	scanResult := streamer.scan()
+	for i := 0; i < len(scanResult) && match(scanResult[i]); i++ {
 	}
+	return all
+}
+
+// NoneMatch 是否没有元素满足pred
+func (streamer *SliceStreamer) NoneMatch(pred interface{}) bool {
+	return !streamer.AnyMatch(pred)
+}
 
+// Partition 按pred将结果分成两部分带出
+func (streamer *SliceStreamer) Partition(pred interface{}, matched interface{}, unmatched interface{}) {
+	fv := streamer.checkPredicate("pred", pred)
+	matchedVal := streamer.checkResultSlice("Partition", matched)
+	unmatchedVal := streamer.checkResultSlice("Partition", unmatched)
 	scanResult := streamer.scan()
-	streamer.toMap(fv, scanResult, &val)
+	matchedData := []interface{}{}
+	unmatchedData := []interface{}{}
+	for i := 0; i < len(scanResult); i++ {
+		if call(fv, scanResult[i])[0].Bool() {
+			matchedData = append(matchedData, scanResult[i])
+		} else {
+			unmatchedData = append(unmatchedData, scanResult[i])
+		}
+	}
+	setSlice(matchedVal, matchedData)
+	setSlice(unmatchedVal, unmatchedData)
 }
 
-// Reduce 根据accumulator两两聚合，结果由result带出
-func (streamer *SliceStreamer) Reduce(accumulator interface{}, result interface{}) {
-	fv := reflect.ValueOf(accumulator)
+// Joining 用sep连接所有元素
+func (streamer *SliceStreamer) Joining(sep string, result interface{}) {
+	streamer.JoiningWith(sep, "", "", result)
+}
+
+// JoiningWith 用sep连接所有元素，并加上prefix和suffix
+func (streamer *SliceStreamer) JoiningWith(sep, prefix, suffix string, result interface{}) {
+	if streamer.curType.Kind() != reflect.String {
+		panic(fmt.Errorf("Joining: upstream mapIter's type must be string, not %s", streamer.curType))
+	}
+	str, ok := result.(*string)
+	if !ok {
+		panic(fmt.Errorf("Joining result must be *string, not %s", reflect.TypeOf(result)))
+	}
+	scanResult := streamer.scan()
+	elems := make([]string, 0, len(scanResult))
+	for i := 0; i < len(scanResult); i++ {
+		elems = append(elems, reflect.ValueOf(scanResult[i]).String())
+	}
+	*str = prefix + strings.Join(elems, sep) + suffix
+}
+
+// CrossJoin 计算和other的笛卡尔积，结果由result带出
+func (streamer *SliceStreamer) CrossJoin(other SliceStream, combiner interface{}, result interface{}) {
+	otherStreamer, ok := other.(*SliceStreamer)
+	if !ok {
+		panic(fmt.Errorf("other must be *SliceStreamer, not %s", reflect.TypeOf(other)))
+	}
+	fv := reflect.ValueOf(combiner)
 	if fv.Kind() != reflect.Func {
-		panic(fmt.Errorf("accumulator must be a function, not %s", fv.Kind()))
+		panic(fmt.Errorf("combiner must be a function, not %s", fv.Kind()))
 	}
 	ft := fv.Type()
 	if ft.NumIn() != 2 {
-		panic(fmt.Errorf("accumulator's args number must equals 2, not %d", ft.NumIn()))
+		panic(fmt.Errorf("combiner's args number must equals 2, not %d", ft.NumIn()))
 	}
-
 	ip1 := ft.In(0)
 	if streamer.curType != ip1 {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's first args type is %s", streamer.curType, ip1))
+		panic(fmt.Errorf("upstream mapIter's type is %s, but combiner's first args type is %s", streamer.curType, ip1))
 	}
-
 	ip2 := ft.In(1)
-	if streamer.curType != ip2 {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's second args type is %s", streamer.curType, ip2))
+	if otherStreamer.curType != ip2 {
+		panic(fmt.Errorf("other mapIter's type is %s, but combiner's second args type is %s", otherStreamer.curType, ip2))
 	}
-
 	if ft.NumOut() != 1 {
-		panic(fmt.Errorf("accumulator's output number must equals 1, not %d", ft.NumOut()))
+		panic(fmt.Errorf("combiner's output number must equals 1, not %d", ft.NumOut()))
 	}
-
-	op1 := ft.Out(0)
-	if streamer.curType != op1 {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but accumulator's return-value type is %s", streamer.curType, op1))
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		panic(errors.New("result must be slice pointer"))
+	}
+	val = val.Elem()
+	if val.Type().Elem() != ft.Out(0) {
+		panic(fmt.Errorf("combiner's return-value type is %s, but CrossJoin's args type is %s", ft.Out(0), val.Type().Elem()))
 	}
 
-	iv := reflect.ValueOf(result)
-	if iv.Kind() != reflect.Ptr {
-		panic(fmt.Errorf("result must be a %s ptr", streamer.curType))
+	left := streamer.scan()
+	right := otherStreamer.scan()
+	newVal := reflect.MakeSlice(val.Type(), 0, len(left)*len(right))
+	for i := 0; i < len(left); i++ {
+		for j := 0; j < len(right); j++ {
+			op := fv.Call([]reflect.Value{valueOf(left[i], streamer.curType), valueOf(right[j], otherStreamer.curType)})
+			newVal = reflect.Append(newVal, op[0])
+		}
 	}
+	val.Set(newVal)
+}
 
-	if iv.Elem().Type() != streamer.curType {
-		panic(fmt.Errorf("accumulator must be a %s, not %s", streamer.curType, iv.Elem().Type()))
+// Equal 按顺序比较两条stream的结果
+func (streamer *SliceStreamer) Equal(other SliceStream) bool {
+	otherStreamer, ok := other.(*SliceStreamer)
+	if !ok {
+		panic(fmt.Errorf("other must be *SliceStreamer, not %s", reflect.TypeOf(other)))
 	}
-	streamer.reduce(fv, iv.Elem())
+	if streamer.curType != otherStreamer.curType {
+		return false
+	}
+	left := streamer.scan()
+	right := otherStreamer.scan()
+	if len(left) != len(right) {
+		return false
+	}
+	for i := 0; i < len(left); i++ {
+		if !reflect.DeepEqual(left[i], right[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 // First 取第一个结果
@@ -539,7 +2471,7 @@ func (streamer *SliceStreamer) First(result interface{}) bool {
 	if val.Type() != streamer.curType {
 		panic(fmt.Errorf("upstream mapIter's type is %s, but First's args type is %s", streamer.curType, val.Type()))
 	}
-	scanResult := streamer.scan()
+	scanResult := streamer.scanHead(1)
 	return streamer.indexAt(0, scanResult, val)
 }
 
@@ -549,60 +2481,303 @@ func (streamer *SliceStreamer) Last(result interface{}) bool {
 	if val.Kind() != reflect.Ptr {
 		panic(errors.New("result must be a pointer"))
 	}
-	val = val.Elem()
-	if val.Type() != streamer.curType {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but Last's args type is %s", streamer.curType, val.Type()))
+	val = val.Elem()
+	if val.Type() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but Last's args type is %s", streamer.curType, val.Type()))
+	}
+	scanResult := streamer.scan()
+	return streamer.indexAt(len(scanResult)-1, scanResult, val)
+}
+
+// IndexAt 取第index个结果（从0开始计数）
+func (streamer *SliceStreamer) IndexAt(index int, result interface{}) bool {
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr {
+		panic(errors.New("result must be a pointer"))
+	}
+	val = val.Elem()
+	if val.Type() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but IndexAt's args type is %s", streamer.curType, val.Type()))
+	}
+	if index < 0 {
+		return false
+	}
+
+	scanResult := streamer.scanHead(index + 1)
+	return streamer.indexAt(index, scanResult, val)
+}
+
+/*
+ * ============================================
+ * 				inner implement
+ * ============================================
+ */
+
+// chain 返回从当前节点到头节点的整条链，链上出现环时panic，避免scan死循环
+func (streamer *SliceStreamer) chain() []*SliceStreamer {
+	streamerList := []*SliceStreamer{}
+	visited := map[*SliceStreamer]struct{}{}
+	for lastStreamer := streamer; lastStreamer != nil; lastStreamer = lastStreamer.lastStreamer {
+		if _, ok := visited[lastStreamer]; ok {
+			panic(errors.New("cycle detected in stream chain"))
+		}
+		visited[lastStreamer] = struct{}{}
+		streamerList = append(streamerList, lastStreamer)
+	}
+	return streamerList
+}
+
+// scan 内部实现，用于其他方法复用
+// 若mapper返回了error，则以第一个error panic
+func (streamer *SliceStreamer) scan() []interface{} {
+	// 设置了Limit时只需要前limit个元素，可以逐个执行并提前结束
+	if streamer.limit > 0 {
+		if data, ok := streamer.scanLazy(streamer.limit); ok {
+			return data
+		}
+	}
+	data, errs := streamer.scanCollectErrors()
+	if len(errs) > 0 {
+		panic(errs[0])
+	}
+	return data
+}
+
+// scanHead 只取结果的前n个元素，能逐个执行时提前结束，否则退化为完整的scan
+func (streamer *SliceStreamer) scanHead(n int) []interface{} {
+	if data, ok := streamer.scanLazy(n); ok {
+		return data
+	}
+	return streamer.scan()
+}
+
+// scanLazy 逐个元素执行整条链，取到n个结果后提前结束；链上有需要全部数据的操作时返回false
+func (streamer *SliceStreamer) scanLazy(n int) ([]interface{}, bool) {
+	data := []interface{}{}
+	ok := streamer.iterate(func(elem interface{}) bool {
+		data = append(data, elem)
+		return len(data) < n
+	})
+	return data, ok
+}
+
+// iterate 逐个元素执行整条链，将结果依次交给yield，yield返回false时停止；
+// 链上有Sorted/Reverse/GroupByStream/Chunk/Window/MapAll等需要全部数据的操作或设置了并行时不执行任何操作并返回false。
+// Peek只会作用于实际被处理的元素，mapper返回error时立刻panic
+func (streamer *SliceStreamer) iterate(yield func(elem interface{}) bool) bool {
+	if !streamer.canIterate() {
+		return false
+	}
+	streamerList := streamer.chain()
+	skipped, taken := 0, 0
+	push := func(elem interface{}) bool {
+		if skipped < streamer.offset {
+			skipped++
+			return true
+		}
+		taken++
+		if !yield(elem) {
+			return false
+		}
+		return streamer.limit <= 0 || taken < streamer.limit
+	}
+	// 设置了SkipLast时暂存最后skipLast个元素，确认之后还有足够的元素时再交给下游
+	if streamer.skipLast > 0 {
+		pending := make([]interface{}, 0, streamer.skipLast)
+		head := 0
+		emit := push
+		push = func(elem interface{}) bool {
+			if len(pending) < streamer.skipLast {
+				pending = append(pending, elem)
+				return true
+			}
+			// pending已满，最早暂存的元素之后至少还有skipLast个元素
+			oldest := pending[head]
+			pending[head] = elem
+			head = (head + 1) % len(pending)
+			return emit(oldest)
+		}
+	}
+	for i := 0; i < len(streamerList); i++ {
+		push = streamerList[i].lazyStage(push)
 	}
-	scanResult := streamer.scan()
-	return streamer.indexAt(len(scanResult)-1, scanResult, val)
+	if getter, ok := streamerList[len(streamerList)-1].dataGetter.(eachGetter); ok {
+		getter.each(push)
+		return true
+	}
+	data := streamerList[len(streamerList)-1].dataGetter.getData()
+	for i := 0; i < len(data) && push(data[i]); i++ {
+	}
+	return true
 }
 
-// IndexAt 取第index个结果（从0开始计数）
-func (streamer *SliceStreamer) IndexAt(index int, result interface{}) bool {
-	val := reflect.ValueOf(result)
-	if val.Kind() != reflect.Ptr {
-		panic(errors.New("result must be a pointer"))
-	}
-	val = val.Elem()
-	if val.Type() != streamer.curType {
-		panic(fmt.Errorf("upstream mapIter's type is %s, but IndexAt's args type is %s", streamer.curType, val.Type()))
+// canIterate 整条链是否可以逐个元素执行
+// 设置了并行的节点逐个执行会失去并行，这时由调用者退化为scan，保持按批并行执行
+func (streamer *SliceStreamer) canIterate() bool {
+	streamerList := streamer.chain()
+	for i := 0; i < len(streamerList); i++ {
+		if !streamerList[i].lazy() || streamerList[i].parallel > 1 {
+			return false
+		}
 	}
+	return true
+}
 
-	scanResult := streamer.scan()
-	return streamer.indexAt(index, scanResult, val)
+// lazy 当前节点是否可以逐个元素执行
+func (streamer *SliceStreamer) lazy() bool {
+	return streamer.sortFunc == nil && !streamer.reverse && streamer.groupFunc == nil &&
+		streamer.windowSize == 0 && streamer.mapAllFunc == nil
 }
 
-/*
- * ============================================
- * 				inner implement
- * ============================================
- */
+// lazyStage 将当前节点的操作包装在next之前，返回的函数处理一个元素，返回false表示不再需要更多元素
+// 操作的顺序和scanCollectErrors相同
+func (streamer *SliceStreamer) lazyStage(next func(elem interface{}) bool) func(elem interface{}) bool {
+	seen := newDistinctSet(0)
+	until := false
+	// index 到达FilterIndexed/MapIndexed的元素的下标
+	index := 0
+	afterMap := func(elem interface{}) bool {
+		if streamer.indexMapper != nil {
+			elem = call(*streamer.indexMapper, index, elem)[0].Interface()
+			index++
+		}
+		if streamer.peekFunc != nil {
+			_ = call(*streamer.peekFunc, elem)
+		}
+		if streamer.untilFunc != nil && call(*streamer.untilFunc, elem)[0].Bool() {
+			until = true
+			return false
+		}
+		if streamer.distinct && !seen.add(elem) {
+			return true
+		}
+		return next(elem)
+	}
+	afterFlatMap := func(elem interface{}) bool {
+		if streamer.mapFunc == nil {
+			return afterMap(elem)
+		}
+		op := call(*streamer.mapFunc, elem)
+		if len(op) == 2 && !op[1].IsNil() {
+			panic(op[1].Interface().(error))
+		}
+		return afterMap(op[0].Interface())
+	}
+	stopped := false
+	var emit reflect.Value
+	if streamer.emitFunc != nil {
+		emit = reflect.MakeFunc(streamer.emitFunc.Type().In(1), func(args []reflect.Value) []reflect.Value {
+			// mapper无法中途停止，停止后产出的元素直接丢弃
+			if !stopped {
+				stopped = !afterFlatMap(args[0].Interface())
+			}
+			return nil
+		})
+	}
+	return func(elem interface{}) bool {
+		if until {
+			return false
+		}
+		for j := 0; j < len(streamer.filterFunc); j++ {
+			if !call(streamer.filterFunc[j], elem)[0].Bool() {
+				return true
+			}
+		}
+		if streamer.indexFilter != nil {
+			keep := call(*streamer.indexFilter, index, elem)[0].Bool()
+			index++
+			if !keep {
+				return true
+			}
+		}
+		if streamer.flatMapFunc != nil {
+			op := call(*streamer.flatMapFunc, elem)
+			for i := 0; i < op[0].Len(); i++ {
+				if !afterFlatMap(op[0].Index(i).Interface()) {
+					return false
+				}
+			}
+			return true
+		}
+		if streamer.emitFunc != nil {
+			streamer.emitFunc.Call([]reflect.Value{valueOf(elem, streamer.emitFunc.Type().In(0)), emit})
+			return !stopped
+		}
+		return afterFlatMap(elem)
+	}
+}
 
-// scan 内部实现，用于其他方法复用
-func (streamer *SliceStreamer) scan() []interface{} {
-	streamerList := []*SliceStreamer{}
-	lastStreamer := streamer
-	for ; lastStreamer != nil; lastStreamer = lastStreamer.lastStreamer {
-		streamerList = append(streamerList, lastStreamer)
+// scanCollectErrors 内部实现，mapper返回error的元素会被丢弃，error按元素顺序收集
+func (streamer *SliceStreamer) scanCollectErrors() ([]interface{}, []error) {
+	errs := []error{}
+	streamerList := streamer.chain()
+	getter := streamerList[len(streamerList)-1].dataGetter
+	newData := getter.getData()
+	// 之后的Sorted/Reverse等操作会原地修改数据，缓存的数据需要先复制
+	if fresh, ok := getter.(freshGetter); !ok || !fresh.fresh() {
+		newData = append([]interface{}{}, newData...)
 	}
-	data := streamerList[len(streamerList)-1].dataGetter.getData()
-	newData := []interface{}{}
-	newData = append(newData, data...)
 	for i := len(streamerList) - 1; i >= 0; i-- {
 		if streamerList[i].filterFunc != nil {
 			newData = streamerList[i].filter(newData)
 		}
+		if streamerList[i].indexFilter != nil {
+			newData = streamerList[i].filterIndexed(newData)
+		}
 		if streamerList[i].flatMapFunc != nil {
 			newData = streamerList[i].flatMap(newData)
 		}
+		if streamerList[i].emitFunc != nil {
+			newData = streamerList[i].mapEmit(newData)
+		}
+		if streamerList[i].mapAllFunc != nil {
+			newData = streamerList[i].mapAll(newData)
+		}
 		if streamerList[i].mapFunc != nil {
-			newData = streamerList[i]._map(newData)
+			var mapErrs []error
+			newData, mapErrs = streamerList[i]._map(newData)
+			errs = append(errs, mapErrs...)
+		}
+		if streamerList[i].indexMapper != nil {
+			newData = streamerList[i].mapIndexed(newData)
 		}
 		if streamerList[i].sortFunc != nil {
-			sort.Slice(newData, func(first, second int) bool {
-				op := call(*streamerList[i].sortFunc, newData[first], newData[second])
-				return op[0].Bool()
-			})
+			newData = streamerList[i].sort(newData)
+		}
+		if streamerList[i].peekFunc != nil {
+			for j := 0; j < len(newData); j++ {
+				_ = call(*streamerList[i].peekFunc, newData[j])
+			}
+		}
+		if streamerList[i].untilFunc != nil {
+			for j := 0; j < len(newData); j++ {
+				if call(*streamerList[i].untilFunc, newData[j])[0].Bool() {
+					newData = newData[:j]
+					break
+				}
+			}
+		}
+		if streamerList[i].distinct {
+			newData = distinct(newData)
+		}
+		if streamerList[i].groupFunc != nil {
+			newData = groupToStream(*streamerList[i].groupFunc, newData)
+		}
+		if streamerList[i].reverse {
+			for l, r := 0, len(newData)-1; l < r; l, r = l+1, r-1 {
+				newData[l], newData[r] = newData[r], newData[l]
+			}
+		}
+		if streamerList[i].windowSize > 0 {
+			newData = window(newData, streamerList[i].windowSize, streamerList[i].windowStep, streamerList[i].keepTail, streamerList[i].curType)
+		}
+	}
+	// skip last
+	if streamer.skipLast > 0 {
+		if streamer.skipLast < len(newData) {
+			newData = newData[:len(newData)-streamer.skipLast]
+		} else {
+			newData = newData[:0]
 		}
 	}
 	// offset limit
@@ -615,7 +2790,7 @@ func (streamer *SliceStreamer) scan() []interface{} {
 		limit = streamer.limit
 	}
 	newData = newData[offset : offset+limit]
-	return newData
+	return newData, errs
 }
 
 // filter 内部实现，用于其他方法复用
@@ -623,30 +2798,40 @@ func (streamer *SliceStreamer) filter(data []interface{}) (result []interface{})
 	if len(streamer.filterFunc) == 0 {
 		return data
 	}
+	if len(data) == 0 {
+		return data
+	}
+	if streamer.workStealing {
+		return streamer.filterStealing(data)
+	}
+	parallel := streamer.effectiveParallel(len(data))
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
-	wg.Add(streamer.parallel)
-	batch := len(data) / streamer.parallel
-	results := make([][]interface{}, streamer.parallel, streamer.parallel)
-	for i := 0; i < streamer.parallel; i++ {
+	wg.Add(parallel)
+	batch := len(data) / parallel
+	results := make([][]interface{}, parallel, parallel)
+	for i := 0; i < parallel; i++ {
 		start := i * batch
 		end := start + batch
-		if i == streamer.parallel-1 && end < len(data) {
+		if i == parallel-1 && end < len(data) {
 			end = len(data)
 		}
-		go func(goroutineID, start, end int) {
+		streamer.spawnBatch(func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
-			res := []interface{}{}
+			res := make([]interface{}, 0, end-start)
+			filters := newCallers(streamer.filterFunc)
 			for i := start; i < end; i++ {
 				isFilter := true
-				for j := 0; j < len(streamer.filterFunc); j++ {
-					op := call(streamer.filterFunc[j], data[i])
-					isFilter = op[0].Bool()
+				for j := 0; j < len(filters); j++ {
+					isFilter = filters[j].test(data[i])
 					if !isFilter {
 						break
 					}
@@ -656,7 +2841,7 @@ func (streamer *SliceStreamer) filter(data []interface{}) (result []interface{})
 				}
 			}
 			results[goroutineID] = res
-		}(i, start, end)
+		}, i, start, end)
 	}
 	wg.Wait()
 	// 内部多个goroutine并行，将内部panic放回主goroutine中
@@ -670,35 +2855,53 @@ func (streamer *SliceStreamer) filter(data []interface{}) (result []interface{})
 }
 
 // _map 内部实现，用于其他方法复用
-func (streamer *SliceStreamer) _map(data []interface{}) (result []interface{}) {
+func (streamer *SliceStreamer) _map(data []interface{}) (result []interface{}, errs []error) {
 	if streamer.mapFunc == nil {
-		return data
+		return data, nil
 	}
+	if len(data) == 0 {
+		return data, nil
+	}
+	if streamer.workStealing {
+		return streamer.mapStealing(data)
+	}
+	parallel := streamer.effectiveParallel(len(data))
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
-	wg.Add(streamer.parallel)
-	batch := len(data) / streamer.parallel
-	results := make([][]interface{}, streamer.parallel, streamer.parallel)
-	for i := 0; i < streamer.parallel; i++ {
+	wg.Add(parallel)
+	batch := len(data) / parallel
+	results := make([][]interface{}, parallel, parallel)
+	errResults := make([][]error, parallel, parallel)
+	for i := 0; i < parallel; i++ {
 		start := i * batch
 		end := start + batch
-		if i == streamer.parallel-1 && end < len(data) {
+		if i == parallel-1 && end < len(data) {
 			end = len(data)
 		}
-		go func(goroutineID, start, end int) {
+		streamer.spawnBatch(func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
-			res := []interface{}{}
+			res := make([]interface{}, 0, end-start)
+			errRes := []error{}
+			mapper := newCaller(*streamer.mapFunc)
 			for i := start; i < end; i++ {
-				op := call(*streamer.mapFunc, data[i])
-				res = append(res, op[0].Interface())
+				out, err := mapper.apply(data[i])
+				if err != nil {
+					errRes = append(errRes, err)
+					continue
+				}
+				res = append(res, out)
 			}
 			results[goroutineID] = res
-		}(i, start, end)
+			errResults[goroutineID] = errRes
+		}, i, start, end)
 	}
 	wg.Wait()
 	// 内部多个goroutine并行，将内部panic放回主goroutine中
@@ -707,8 +2910,108 @@ func (streamer *SliceStreamer) _map(data []interface{}) (result []interface{}) {
 	}
 	for i := 0; i < len(results); i++ {
 		result = append(result, results[i]...)
+		errs = append(errs, errResults[i]...)
 	}
-	return result
+	return result, errs
+}
+
+// parallelSortThreshold 并行度大于1且元素数不少于该值时使用并行归并排序，元素较少时创建goroutine的开销大于收益
+const parallelSortThreshold = 8192
+
+// sort 稳定排序的内部实现，会修改data，返回排序后的结果
+func (streamer *SliceStreamer) sort(data []interface{}) []interface{} {
+	less := func(a, b interface{}) bool {
+		return call(*streamer.sortFunc, a, b)[0].Bool()
+	}
+	parallel := streamer.effectiveParallel(len(data))
+	if parallel <= 1 || len(data) < parallelSortThreshold {
+		sort.SliceStable(data, func(first, second int) bool {
+			return less(data[first], data[second])
+		})
+		return data
+	}
+	return streamer.parallelSort(data, parallel, less)
+}
+
+// parallelSort 并行归并排序：将data切分成parallel段，每段在各自的goroutine中稳定排序，再并行地两两归并直到只剩一段
+// 归并时相等的元素优先取左边一段的元素，因此整体仍是稳定的
+func (streamer *SliceStreamer) parallelSort(data []interface{}, parallel int, less func(a, b interface{}) bool) []interface{} {
+	// 第i段为[bounds[i], bounds[i+1])，切分方式和filter/map相同
+	batch := len(data) / parallel
+	bounds := make([]int, parallel+1)
+	for i := 0; i < parallel; i++ {
+		bounds[i] = i * batch
+	}
+	bounds[parallel] = len(data)
+	streamer.runParallel(parallel, func(i int) {
+		part := data[bounds[i]:bounds[i+1]]
+		sort.SliceStable(part, func(first, second int) bool {
+			return less(part[first], part[second])
+		})
+	})
+	buf := make([]interface{}, len(data))
+	for len(bounds) > 2 {
+		pairs := len(bounds) / 2
+		streamer.runParallel(pairs, func(i int) {
+			start, mid, end := bounds[2*i], bounds[2*i+1], bounds[2*i+1]
+			// 段数为奇数时最后一段没有可以归并的段，直接复制
+			if 2*i+2 < len(bounds) {
+				end = bounds[2*i+2]
+			}
+			mergeSorted(buf[start:end], data[start:mid], data[mid:end], less)
+		})
+		data, buf = buf, data
+		merged := make([]int, 0, pairs+1)
+		for i := 0; i < len(bounds); i += 2 {
+			merged = append(merged, bounds[i])
+		}
+		if merged[len(merged)-1] != len(data) {
+			merged = append(merged, len(data))
+		}
+		bounds = merged
+	}
+	return data
+}
+
+// runParallel 并行执行task(0)到task(n-1)并等待全部结束，将内部panic放回当前goroutine中
+func (streamer *SliceStreamer) runParallel(n int, task func(i int)) {
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicError error
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		streamer.spawnBatch(func(goroutineID, _, _ int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
+				}
+				wg.Done()
+			}()
+			task(goroutineID)
+		}, i, 0, 0)
+	}
+	wg.Wait()
+	if panicError != nil {
+		panic(panicError)
+	}
+}
+
+// mergeSorted 将有序的left和right归并到dst中，相等时优先取left中的元素
+func mergeSorted(dst, left, right []interface{}, less func(a, b interface{}) bool) {
+	i, j, k := 0, 0, 0
+	for ; i < len(left) && j < len(right); k++ {
+		if less(right[j], left[i]) {
+			dst[k] = right[j]
+			j++
+		} else {
+			dst[k] = left[i]
+			i++
+		}
+	}
+	k += copy(dst[k:], left[i:])
+	copy(dst[k:], right[j:])
 }
 
 // reduce 内部实现，用于其他方法复用
@@ -717,13 +3020,9 @@ func (streamer *SliceStreamer) reduce(fv, iv reflect.Value) {
 	if len(data) == 0 {
 		return
 	}
-	if len(data) == 1 {
-		iv.Set(reflect.ValueOf(data[0]))
-		return
-	}
 	baseVal := iv
 	for i := 0; i < len(data); i++ {
-		baseVal = fv.Call([]reflect.Value{baseVal, reflect.ValueOf(data[i])})[0]
+		baseVal = fv.Call([]reflect.Value{baseVal, valueOf(data[i], streamer.curType)})[0]
 	}
 	iv.Set(baseVal)
 }
@@ -731,57 +3030,377 @@ func (streamer *SliceStreamer) reduce(fv, iv reflect.Value) {
 // flatMap 内部实现，用于其他方法复用
 func (streamer *SliceStreamer) flatMap(data []interface{}) (result []interface{}) {
 	if streamer.flatMapFunc == nil {
-		return streamer.dataGetter.getData()
+		return data
+	}
+	if len(data) == 0 {
+		return data
+	}
+	if streamer.workStealing {
+		return streamer.flatMapStealing(data)
 	}
+	parallel := streamer.effectiveParallel(len(data))
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
-	wg.Add(streamer.parallel)
-	batch := len(data) / streamer.parallel
-	results := make([][]interface{}, streamer.parallel, streamer.parallel)
-	for i := 0; i < streamer.parallel; i++ {
+	wg.Add(parallel)
+	batch := len(data) / parallel
+	results := make([][]interface{}, parallel, parallel)
+	for i := 0; i < parallel; i++ {
 		start := i * batch
 		end := start + batch
-		if i == streamer.parallel-1 && end < len(data) {
+		if i == parallel-1 && end < len(data) {
 			end = len(data)
 		}
-		go func(goroutineID, start, end int) {
+		streamer.spawnBatch(func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
 			res := []interface{}{}
+			flatMapper := newCaller(*streamer.flatMapFunc)
 			for i := start; i < end; i++ {
-				op := call(*streamer.flatMapFunc, data[i])
+				op := flatMapper.call1(data[i])
 				for i := 0; i < op[0].Len(); i++ {
 					res = append(res, op[0].Index(i).Interface())
 				}
 			}
 			results[goroutineID] = res
-		}(i, start, end)
+		}, i, start, end)
 	}
 	wg.Wait()
 	// 内部多个goroutine并行，将内部panic放回主goroutine中
 	if panicError != nil {
 		panic(panicError)
 	}
-	for i := 0; i < len(results); i++ {
-		result = append(result, results[i]...)
+	for i := 0; i < len(results); i++ {
+		result = append(result, results[i]...)
+	}
+	return result
+}
+
+// mapAll 内部实现，将data转换成[]T交给mapper，再将返回的[]O展开
+func (streamer *SliceStreamer) mapAll(data []interface{}) []interface{} {
+	items := reflect.MakeSlice(streamer.mapAllFunc.Type().In(0), len(data), len(data))
+	for i := 0; i < len(data); i++ {
+		items.Index(i).Set(valueOf(data[i], items.Type().Elem()))
+	}
+	op := streamer.mapAllFunc.Call([]reflect.Value{items})[0]
+	result := make([]interface{}, 0, op.Len())
+	for i := 0; i < op.Len(); i++ {
+		result = append(result, op.Index(i).Interface())
+	}
+	return result
+}
+
+// filterIndexed 内部实现，按顺序串行执行
+func (streamer *SliceStreamer) filterIndexed(data []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if call(*streamer.indexFilter, i, data[i])[0].Bool() {
+			result = append(result, data[i])
+		}
+	}
+	return result
+}
+
+// mapIndexed 内部实现，按顺序串行执行
+func (streamer *SliceStreamer) mapIndexed(data []interface{}) []interface{} {
+	result := make([]interface{}, len(data))
+	for i := 0; i < len(data); i++ {
+		result[i] = call(*streamer.indexMapper, i, data[i])[0].Interface()
+	}
+	return result
+}
+
+// mapEmit 内部实现，每个goroutine持有一个emit函数，将产出的元素追加到自己的结果中
+func (streamer *SliceStreamer) mapEmit(data []interface{}) (result []interface{}) {
+	if len(data) == 0 {
+		return data
+	}
+	emitType := streamer.emitFunc.Type().In(1)
+	parallel := streamer.effectiveParallel(len(data))
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicError error
+	wg.Add(parallel)
+	batch := len(data) / parallel
+	results := make([][]interface{}, parallel, parallel)
+	for i := 0; i < parallel; i++ {
+		start := i * batch
+		end := start + batch
+		if i == parallel-1 && end < len(data) {
+			end = len(data)
+		}
+		streamer.spawnBatch(func(goroutineID, start, end int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
+				}
+				wg.Done()
+			}()
+			res := []interface{}{}
+			emit := reflect.MakeFunc(emitType, func(args []reflect.Value) []reflect.Value {
+				res = append(res, args[0].Interface())
+				return nil
+			})
+			for i := start; i < end; i++ {
+				streamer.emitFunc.Call([]reflect.Value{valueOf(data[i], streamer.emitFunc.Type().In(0)), emit})
+			}
+			results[goroutineID] = res
+		}, i, start, end)
+	}
+	wg.Wait()
+	// 内部多个goroutine并行，将内部panic放回主goroutine中
+	if panicError != nil {
+		panic(panicError)
+	}
+	for i := 0; i < len(results); i++ {
+		result = append(result, results[i]...)
+	}
+	return result
+}
+
+// spawn 执行task，设置了worker pool时交给pool执行，否则创建新的goroutine
+func (streamer *SliceStreamer) spawn(task func()) {
+	if streamer.pool != nil {
+		streamer.pool.submit(task)
+		return
+	}
+	go task()
+}
+
+// spawnBatch 以goroutineID和[start, end)区间执行task
+func (streamer *SliceStreamer) spawnBatch(task func(goroutineID, start, end int), goroutineID, start, end int) {
+	streamer.spawn(func() {
+		task(goroutineID, start, end)
+	})
+}
+
+// effectiveParallel 实际使用的并行度，不超过数据量，避免创建没有数据可处理的goroutine
+func (streamer *SliceStreamer) effectiveParallel(n int) int {
+	if n < streamer.parallel {
+		return n
+	}
+	return streamer.parallel
+}
+
+// stealWork 工作窃取模式的内部实现
+// parallel个goroutine通过共享的原子下标动态领取元素，handle按下标写入结果，从而保证顺序
+// 每个goroutine通过newHandle创建自己的handle，handle可以持有只在当前goroutine中使用的状态
+func (streamer *SliceStreamer) stealWork(n int, newHandle func() func(index int)) {
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicError error
+	next := int64(-1)
+	parallel := streamer.effectiveParallel(n)
+	wg.Add(parallel)
+	for i := 0; i < parallel; i++ {
+		streamer.spawn(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
+				}
+				wg.Done()
+			}()
+			handle := newHandle()
+			for {
+				index := int(atomic.AddInt64(&next, 1))
+				if index >= n {
+					return
+				}
+				handle(index)
+			}
+		})
+	}
+	wg.Wait()
+	// 内部多个goroutine并行，将内部panic放回主goroutine中
+	if panicError != nil {
+		panic(panicError)
+	}
+}
+
+// filterStealing 工作窃取模式下的filter
+func (streamer *SliceStreamer) filterStealing(data []interface{}) (result []interface{}) {
+	keep := make([]bool, len(data))
+	streamer.stealWork(len(data), func() func(index int) {
+		filters := newCallers(streamer.filterFunc)
+		return func(index int) {
+			isFilter := true
+			for j := 0; j < len(filters); j++ {
+				isFilter = filters[j].test(data[index])
+				if !isFilter {
+					break
+				}
+			}
+			keep[index] = isFilter
+		}
+	})
+	for i := 0; i < len(data); i++ {
+		if keep[i] {
+			result = append(result, data[i])
+		}
+	}
+	return result
+}
+
+// mapStealing 工作窃取模式下的_map
+func (streamer *SliceStreamer) mapStealing(data []interface{}) (result []interface{}, errs []error) {
+	outs := make([]interface{}, len(data))
+	outErrs := make([]error, len(data))
+	streamer.stealWork(len(data), func() func(index int) {
+		mapper := newCaller(*streamer.mapFunc)
+		return func(index int) {
+			out, err := mapper.apply(data[index])
+			if err != nil {
+				outErrs[index] = err
+				return
+			}
+			outs[index] = out
+		}
+	})
+	for i := 0; i < len(data); i++ {
+		if outErrs[i] != nil {
+			errs = append(errs, outErrs[i])
+			continue
+		}
+		result = append(result, outs[i])
+	}
+	return result, errs
+}
+
+// flatMapStealing 工作窃取模式下的flatMap
+func (streamer *SliceStreamer) flatMapStealing(data []interface{}) (result []interface{}) {
+	outs := make([][]interface{}, len(data))
+	streamer.stealWork(len(data), func() func(index int) {
+		flatMapper := newCaller(*streamer.flatMapFunc)
+		return func(index int) {
+			op := flatMapper.call1(data[index])
+			res := make([]interface{}, 0, op[0].Len())
+			for i := 0; i < op[0].Len(); i++ {
+				res = append(res, op[0].Index(i).Interface())
+			}
+			outs[index] = res
+		}
+	})
+	for i := 0; i < len(outs); i++ {
+		result = append(result, outs[i]...)
+	}
+	return result
+}
+
+// window 按size和step切分data，每个窗口是windowType类型的slice；keepTail为true时保留不足size个元素的最后一个窗口（Chunk）
+func window(data []interface{}, size, step int, keepTail bool, windowType reflect.Type) []interface{} {
+	result := make([]interface{}, 0, (len(data)+step-1)/step)
+	for start := 0; start < len(data); start += step {
+		end := start + size
+		if end > len(data) {
+			if !keepTail {
+				break
+			}
+			end = len(data)
+		}
+		w := reflect.MakeSlice(windowType, 0, end-start)
+		for j := start; j < end; j++ {
+			w = reflect.Append(w, valueOf(data[j], w.Type().Elem()))
+		}
+		result = append(result, w.Interface())
+	}
+	return result
+}
+
+// groupToStream GroupByStream的内部实现，分组按key第一次出现的顺序排列
+func groupToStream(keyer reflect.Value, data []interface{}) []interface{} {
+	indexes := map[interface{}]int{}
+	groups := []interface{}{}
+	for i := 0; i < len(data); i++ {
+		key := call(keyer, data[i])[0].Interface()
+		index, ok := indexes[key]
+		if !ok {
+			index = len(groups)
+			indexes[key] = index
+			groups = append(groups, Group{Key: key})
+		}
+		group := groups[index].(Group)
+		group.Items = append(group.Items, data[i])
+		groups[index] = group
+	}
+	return groups
+}
+
+// distinct 内部实现，保留第一次出现的元素
+func distinct(data []interface{}) []interface{} {
+	seen := newDistinctSet(len(data))
+	result := make([]interface{}, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if seen.add(data[i]) {
+			result = append(result, data[i])
+		}
+	}
+	return result
+}
+
+// distinctSet Distinct使用的去重集合
+// 上游类型为interface时，元素的动态类型可能是slice、map等不可比较的类型，不能作为map的key，
+// 这些元素退化为使用reflect.DeepEqual逐个比较
+type distinctSet struct {
+	comparable   map[interface{}]struct{}
+	uncomparable []interface{}
+}
+
+func newDistinctSet(size int) *distinctSet {
+	return &distinctSet{comparable: make(map[interface{}]struct{}, size)}
+}
+
+// add 将elem加入集合，elem已经存在时返回false
+func (set *distinctSet) add(elem interface{}) bool {
+	if elem == nil || reflect.TypeOf(elem).Comparable() {
+		if added, ok := set.addComparable(elem); ok {
+			return added
+		}
+	}
+	for i := 0; i < len(set.uncomparable); i++ {
+		if reflect.DeepEqual(set.uncomparable[i], elem) {
+			return false
+		}
+	}
+	set.uncomparable = append(set.uncomparable, elem)
+	return true
+}
+
+// addComparable 使用map去重，ok为false表示elem不能作为map的key
+// 类型可比较的结构体、数组中的interface字段也可能保存着不可比较的值，这时取hash会panic
+func (set *distinctSet) addComparable(elem interface{}) (added bool, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			added, ok = false, false
+		}
+	}()
+	if _, exist := set.comparable[elem]; exist {
+		return false, true
 	}
-	return result
+	set.comparable[elem] = struct{}{}
+	return true, true
 }
 
 // groupBy GroupBy内部实现，支持并行
 func (streamer *SliceStreamer) groupBy(keyer reflect.Value, scanResult []interface{}, valPointer *reflect.Value) {
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
 	wg.Add(streamer.parallel)
 	val := *valPointer
 	batch := len(scanResult) / streamer.parallel
 	// collect results from different worker goroutine
-	// make the cap equals streamer.parallel, and use iteration index as goroutineID to avoid concurrent problem
-	resultCollection := make(map[int]map[interface{}][]interface{}, streamer.parallel)
+	// pre-allocate one slot per goroutine, and use iteration index as goroutineID to avoid concurrent map writes
+	resultCollection := make([]map[interface{}][]interface{}, streamer.parallel)
 
 	for i := 0; i < streamer.parallel; i++ {
 		start := i * batch
@@ -790,10 +3409,12 @@ func (streamer *SliceStreamer) groupBy(keyer reflect.Value, scanResult []interfa
 			end = len(scanResult)
 		}
 		// new worker goroutine
-		go func(goroutineID, start, end int) {
+		streamer.spawnBatch(func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
@@ -809,7 +3430,7 @@ func (streamer *SliceStreamer) groupBy(keyer reflect.Value, scanResult []interfa
 				valList = append(valList, scanResult[j])
 				curGoroutineMap[key] = valList
 			}
-		}(i, start, end)
+		}, i, start, end)
 	}
 	wg.Wait()
 	// 内部多个goroutine并行，将内部panic放回主goroutine中
@@ -817,30 +3438,84 @@ func (streamer *SliceStreamer) groupBy(keyer reflect.Value, scanResult []interfa
 		panic(panicError)
 	}
 	// merge results from different worker goroutine
+	// 每个goroutine处理的是连续的下标区间，按goroutineID的顺序合并即可保证分组内的元素保持原有顺序
 	for i := 0; i < streamer.parallel; i++ {
 		goroutineMap := resultCollection[i]
 		for k, v := range goroutineMap {
-			valList := val.MapIndex(reflect.ValueOf(k))
+			key := valueOf(k, val.Type().Key())
+			valList := val.MapIndex(key)
 			if !valList.IsValid() {
 				valList = reflect.MakeSlice(val.Type().Elem(), 0, len(v))
 			}
 			for j := 0; j < len(v); j++ {
-				valList = reflect.Append(valList, reflect.ValueOf(v[j]))
+				valList = reflect.Append(valList, valueOf(v[j], streamer.curType))
+			}
+			val.SetMapIndex(key, valList)
+		}
+	}
+}
+
+// countBy 内部实现，和groupBy相同，每个goroutine各自计数后再合并
+func (streamer *SliceStreamer) countBy(keyer reflect.Value, scanResult []interface{}, valPointer *reflect.Value) {
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicError error
+	wg.Add(streamer.parallel)
+	val := *valPointer
+	batch := len(scanResult) / streamer.parallel
+	resultCollection := make([]map[interface{}]int, streamer.parallel)
+
+	for i := 0; i < streamer.parallel; i++ {
+		start := i * batch
+		end := start + batch
+		if i == streamer.parallel-1 && end < len(scanResult) {
+			end = len(scanResult)
+		}
+		streamer.spawnBatch(func(goroutineID, start, end int) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
+				}
+				wg.Done()
+			}()
+			curGoroutineMap := map[interface{}]int{}
+			resultCollection[goroutineID] = curGoroutineMap
+			for j := start; j < end; j++ {
+				op := call(keyer, scanResult[j])
+				curGoroutineMap[op[0].Interface()]++
+			}
+		}, i, start, end)
+	}
+	wg.Wait()
+	// 内部多个goroutine并行，将内部panic放回主goroutine中
+	if panicError != nil {
+		panic(panicError)
+	}
+	// merge results from different worker goroutine
+	for i := 0; i < streamer.parallel; i++ {
+		for k, v := range resultCollection[i] {
+			key := valueOf(k, val.Type().Key())
+			count := val.MapIndex(key)
+			if !count.IsValid() {
+				count = reflect.Zero(val.Type().Elem())
 			}
-			val.SetMapIndex(reflect.ValueOf(k), valList)
+			val.SetMapIndex(key, reflect.ValueOf(int(count.Int())+v).Convert(val.Type().Elem()))
 		}
 	}
 }
 
 func (streamer *SliceStreamer) toMap(keyer reflect.Value, scanResult []interface{}, valPointer *reflect.Value) {
 	var wg sync.WaitGroup
+	var panicOnce sync.Once
 	var panicError error
 	wg.Add(streamer.parallel)
 	val := *valPointer
 	batch := len(scanResult) / streamer.parallel
 	// collect results from different worker goroutine
-	// make the cap equals streamer.parallel, and use iteration index as goroutineID to avoid concurrent problem
-	resultCollection := make(map[int]map[interface{}]interface{}, streamer.parallel)
+	// pre-allocate one slot per goroutine, and use iteration index as goroutineID to avoid concurrent map writes
+	resultCollection := make([]map[interface{}]interface{}, streamer.parallel)
 
 	for i := 0; i < streamer.parallel; i++ {
 		start := i * batch
@@ -849,10 +3524,12 @@ func (streamer *SliceStreamer) toMap(keyer reflect.Value, scanResult []interface
 			end = len(scanResult)
 		}
 		// new worker goroutine
-		go func(goroutineID, start, end int) {
+		streamer.spawnBatch(func(goroutineID, start, end int) {
 			defer func() {
 				if r := recover(); r != nil {
-					panicError = fmt.Errorf("panic: %s", r)
+					panicOnce.Do(func() {
+						panicError = newPanicError(r)
+					})
 				}
 				wg.Done()
 			}()
@@ -863,7 +3540,7 @@ func (streamer *SliceStreamer) toMap(keyer reflect.Value, scanResult []interface
 				key := op[0].Interface()
 				curGoroutineMap[key] = scanResult[j]
 			}
-		}(i, start, end)
+		}, i, start, end)
 	}
 	wg.Wait()
 	// 内部多个goroutine并行，将内部panic放回主goroutine中
@@ -874,9 +3551,109 @@ func (streamer *SliceStreamer) toMap(keyer reflect.Value, scanResult []interface
 	for i := 0; i < streamer.parallel; i++ {
 		goroutineMap := resultCollection[i]
 		for k, v := range goroutineMap {
-			val.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+			val.SetMapIndex(valueOf(k, val.Type().Key()), valueOf(v, streamer.curType))
+		}
+	}
+}
+
+// extreme Min/Max的内部实现，max为true时取最大值，否则取最小值
+func (streamer *SliceStreamer) extreme(less reflect.Value, val reflect.Value, max bool) bool {
+	scanResult := streamer.scan()
+	if len(scanResult) == 0 {
+		return false
+	}
+	best := scanResult[0]
+	for i := 1; i < len(scanResult); i++ {
+		if max && call(less, best, scanResult[i])[0].Bool() ||
+			!max && call(less, scanResult[i], best)[0].Bool() {
+			best = scanResult[i]
+		}
+	}
+	val.Set(valueOf(best, streamer.curType))
+	return true
+}
+
+// extremeBy MinBy/MaxBy的内部实现，max为true时取key最大的元素，否则取key最小的元素
+func (streamer *SliceStreamer) extremeBy(keyer reflect.Value, val reflect.Value, max bool) bool {
+	scanResult := streamer.scan()
+	if len(scanResult) == 0 {
+		return false
+	}
+	best := scanResult[0]
+	bestKey := call(keyer, best)[0]
+	for i := 1; i < len(scanResult); i++ {
+		key := call(keyer, scanResult[i])[0]
+		if max && lessOrdered(bestKey, key) || !max && lessOrdered(key, bestKey) {
+			best, bestKey = scanResult[i], key
+		}
+	}
+	val.Set(valueOf(best, streamer.curType))
+	return true
+}
+
+// deepCopy 通过反射深拷贝v，未导出的结构体字段只做浅拷贝
+// visited记录已经拷贝过的指针和map，再次遇到时复用已有的拷贝，避免循环引用导致无限递归
+func deepCopy(v reflect.Value, visited map[uintptr]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		// 指向结构体和指向其第一个字段的指针地址相同，需要同时比较类型
+		if copied, ok := visited[v.Pointer()]; ok && copied.Type() == v.Type() {
+			return copied
+		}
+		newVal := reflect.New(v.Type().Elem())
+		visited[v.Pointer()] = newVal
+		newVal.Elem().Set(deepCopy(v.Elem(), visited))
+		return newVal
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
 		}
+		newVal := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			newVal.Index(i).Set(deepCopy(v.Index(i), visited))
+		}
+		return newVal
+	case reflect.Array:
+		newVal := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			newVal.Index(i).Set(deepCopy(v.Index(i), visited))
+		}
+		return newVal
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		if copied, ok := visited[v.Pointer()]; ok && copied.Type() == v.Type() {
+			return copied
+		}
+		newVal := reflect.MakeMapWithSize(v.Type(), v.Len())
+		visited[v.Pointer()] = newVal
+		iter := v.MapRange()
+		for iter.Next() {
+			newVal.SetMapIndex(deepCopy(iter.Key(), visited), deepCopy(iter.Value(), visited))
+		}
+		return newVal
+	case reflect.Struct:
+		newVal := reflect.New(v.Type()).Elem()
+		newVal.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if newVal.Field(i).CanSet() {
+				newVal.Field(i).Set(deepCopy(v.Field(i), visited))
+			}
+		}
+		return newVal
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		newVal := reflect.New(v.Type()).Elem()
+		newVal.Set(deepCopy(v.Elem(), visited))
+		return newVal
 	}
+	return v
 }
 
 // indexAt IndexAt的内部实现
@@ -884,14 +3661,440 @@ func (streamer *SliceStreamer) indexAt(index int, scanResult []interface{}, val
 	if len(scanResult) <= index {
 		return false
 	}
-	val.Set(reflect.ValueOf(scanResult[index]))
+	val.Set(valueOf(scanResult[index], streamer.curType))
 	return true
 }
 
+// checkPredicate 校验判断函数，判断函数应为 func (item T) bool
+func (streamer *SliceStreamer) checkPredicate(name string, pred interface{}) reflect.Value {
+	fv := reflect.ValueOf(pred)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("%s's args number must equals 1, not %d", name, ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, ip1))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("%s's output number must equals 1, not %d", name, ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if op1.Kind() != reflect.Bool {
+		panic(fmt.Errorf("%s's return-val type should be bool, not %s", name, op1))
+	}
+	return fv
+}
+
+// checkOp 校验op函数，op函数应为 func (item T)，没有返回值
+func (streamer *SliceStreamer) checkOp(name string, op interface{}) reflect.Value {
+	fv := reflect.ValueOf(op)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("%s's args number must equals 1, not %d", name, ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, ip1))
+	}
+	if ft.NumOut() != 0 {
+		panic(fmt.Errorf("%s's output number must equals 0, not %d", name, ft.NumOut()))
+	}
+	return fv
+}
+
+// checkKeyer 校验key函数，key函数应为 func (item T) K，K必须是可比较的类型
+func (streamer *SliceStreamer) checkKeyer(name string, keyer interface{}) reflect.Value {
+	fv := reflect.ValueOf(keyer)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("%s's args number must equals 1, not %d", name, ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, ip1))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("%s's output number must equals 1, not %d", name, ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if !op1.Comparable() {
+		panic(fmt.Errorf("%s's return-val type should be comparable, but %s is not", name, op1))
+	}
+	return fv
+}
+
+// checkSelector 校验提取函数，提取函数应为 func (item T) O，O可以是任意类型
+func (streamer *SliceStreamer) checkSelector(name string, selector interface{}) reflect.Value {
+	fv := reflect.ValueOf(selector)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("%s's args number must equals 1, not %d", name, ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, ip1))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("%s's output number must equals 1, not %d", name, ft.NumOut()))
+	}
+	return fv
+}
+
+// checkAccumulator 校验聚合函数，聚合函数应为 func (a, b T) T
+func (streamer *SliceStreamer) checkAccumulator(name string, accumulator interface{}) reflect.Value {
+	fv := reflect.ValueOf(accumulator)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("%s's args number must equals 2, not %d", name, ft.NumIn()))
+	}
+	if ip1, ip2 := ft.In(0), ft.In(1); streamer.curType != ip1 || streamer.curType != ip2 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is (%s, %s)", streamer.curType, name, ip1, ip2))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("%s's output number must equals 1, not %d", name, ft.NumOut()))
+	}
+	if op1 := ft.Out(0); streamer.curType != op1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's return-value type is %s", streamer.curType, name, op1))
+	}
+	return fv
+}
+
+// checkOrderedKeyer 校验keyer，keyer的返回值必须可以用<比较
+func (streamer *SliceStreamer) checkOrderedKeyer(name string, keyer interface{}) reflect.Value {
+	fv := streamer.checkKeyer(name, keyer)
+	if op1 := fv.Type().Out(0); !isOrdered(op1) {
+		panic(fmt.Errorf("%s's return-val type should be integer, float or string, but %s is not", name, op1))
+	}
+	return fv
+}
+
+// checkNumericSelector 校验数值提取函数，提取函数应为 func (item T) N，N为数值类型
+func (streamer *SliceStreamer) checkNumericSelector(name string, selector interface{}) reflect.Value {
+	fv := reflect.ValueOf(selector)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 {
+		panic(fmt.Errorf("%s's args number must equals 1, not %d", name, ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	if streamer.curType != ip1 {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, ip1))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("%s's output number must equals 1, not %d", name, ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if !isNumeric(op1) {
+		panic(fmt.Errorf("%s's return-val type should be numeric, not %s", name, op1))
+	}
+	return fv
+}
+
+// checkLess 校验比较函数，比较函数应为 func (item1, item2 T) bool
+func (streamer *SliceStreamer) checkLess(name string, less interface{}) reflect.Value {
+	fv := reflect.ValueOf(less)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Errorf("%s must be a function, not %s", name, fv.Kind()))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 {
+		panic(fmt.Errorf("%s's args number must equals 2, not %d", name, ft.NumIn()))
+	}
+	ip1 := ft.In(0)
+	ip2 := ft.In(1)
+	if ip1 != ip2 {
+		panic(fmt.Errorf("%s: first param type (%s) is different with second param type (%s)", name, ip1, ip2))
+	}
+	if ip1 != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, ip1))
+	}
+	if ft.NumOut() != 1 {
+		panic(fmt.Errorf("%s's output number must equals 1, not %d", name, ft.NumOut()))
+	}
+	op1 := ft.Out(0)
+	if op1.Kind() != reflect.Bool {
+		panic(fmt.Errorf("%s's return-val type should be bool, not %s", name, op1))
+	}
+	return fv
+}
+
+// isNumeric 判断是否为数值类型
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// quickSelect 返回data按less排序后的第n个元素，会打乱data的顺序
+// 使用随机pivot和三路划分，大量相等元素时也不会退化
+func quickSelect(data []interface{}, n int, less func(a, b interface{}) bool) interface{} {
+	lo, hi := 0, len(data)-1
+	for lo < hi {
+		pivot := data[lo+rand.Intn(hi-lo+1)]
+		// 划分后 [lo, lt) < pivot，[lt, gt] == pivot，(gt, hi] > pivot
+		lt, i, gt := lo, lo, hi
+		for i <= gt {
+			if less(data[i], pivot) {
+				data[lt], data[i] = data[i], data[lt]
+				lt++
+				i++
+			} else if less(pivot, data[i]) {
+				data[i], data[gt] = data[gt], data[i]
+				gt--
+			} else {
+				i++
+			}
+		}
+		if n < lt {
+			hi = lt - 1
+		} else if n > gt {
+			lo = gt + 1
+		} else {
+			return data[n]
+		}
+	}
+	return data[n]
+}
+
+// isOrdered 是否是可以用<比较的类型
+func isOrdered(t reflect.Type) bool {
+	return isNumeric(t) || t.Kind() == reflect.String
+}
+
+// lessOrdered 比较两个同类型的可排序值，a < b时返回true
+func lessOrdered(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	}
+	panic(fmt.Errorf("%s is not ordered", a.Type()))
+}
+
+// addNumeric 将数值v累加到sum上，sum和v必须是同一种数值类型
+func addNumeric(sum, v reflect.Value) {
+	switch sum.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sum.SetInt(sum.Int() + v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sum.SetUint(sum.Uint() + v.Uint())
+	case reflect.Float32, reflect.Float64:
+		sum.SetFloat(sum.Float() + v.Float())
+	}
+}
+
+// toFloat64 将数值转换成float64
+func toFloat64(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	panic(fmt.Errorf("%s is not numeric", v.Type()))
+}
+
+// checkResultPtr 校验result是T类型的指针，返回指针指向的值
+func (streamer *SliceStreamer) checkResultPtr(name string, result interface{}) reflect.Value {
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("result must be a %s ptr", streamer.curType))
+	}
+	val = val.Elem()
+	if val.Type() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, val.Type()))
+	}
+	return val
+}
+
+// checkResultSlice 校验result是[]T类型的指针，返回指针指向的slice
+func (streamer *SliceStreamer) checkResultSlice(name string, result interface{}) reflect.Value {
+	val := reflect.ValueOf(result)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		panic(errors.New("result must be slice pointer"))
+	}
+	val = val.Elem()
+	if val.Type().Elem() != streamer.curType {
+		panic(fmt.Errorf("upstream mapIter's type is %s, but %s's args type is %s", streamer.curType, name, val.Type().Elem()))
+	}
+	return val
+}
+
+// fillSlice 用data替换val中已有的数据，容量足够时复用val的底层数组
+// 先一次性调整长度再按下标Set，避免逐个Append带来的多次扩容
+func fillSlice(val reflect.Value, data []interface{}) {
+	resizeSlice(val, len(data))
+	for i := 0; i < len(data); i++ {
+		val.Index(i).Set(valueOf(data[i], val.Type().Elem()))
+	}
+}
+
+// resizeSlice 将val的长度调整为n，容量不够时重新分配
+func resizeSlice(val reflect.Value, n int) {
+	if val.Cap() < n {
+		val.Set(reflect.MakeSlice(val.Type(), n, n))
+	} else {
+		val.SetLen(n)
+	}
+}
+
+// setSlice 用data替换val中已有的数据
+func setSlice(val reflect.Value, data []interface{}) {
+	newVal := reflect.MakeSlice(val.Type(), 0, len(data))
+	for i := 0; i < len(data); i++ {
+		newVal = reflect.Append(newVal, valueOf(data[i], val.Type().Elem()))
+	}
+	val.Set(newVal)
+}
+
+// recoverError 将panic转换为error写入err，需要直接被defer调用
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("panic: %v", r)
+		}
+	}
+}
+
+// fixParallel 将并行度限制在[1, 2 * cpu_num]之间
+func fixParallel(parallel int) int {
+	// at least 1 parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	// max parallel = 2 * cpu_num
+	if parallel > runtime.NumCPU()*2 {
+		parallel = runtime.NumCPU() * 2
+	}
+	return parallel
+}
+
+// caller 复用参数slice调用函数，避免每次调用都分配[]reflect.Value，不能在多个goroutine之间共用
+// 对常见的函数签名会直接断言成具体的函数类型调用，绕过reflect.Value.Call的分配
+type caller struct {
+	fv reflect.Value
+	in []reflect.Value
+	// argType 函数的参数类型，nil元素以该类型的零值传入
+	argType reflect.Type
+	// pred 签名为 func(T) bool 的常见函数的快速路径
+	pred func(arg interface{}) bool
+	// mapper 签名为 func(T) O 的常见函数的快速路径
+	mapper func(arg interface{}) interface{}
+}
+
+func newCaller(fv reflect.Value) *caller {
+	c := &caller{fv: fv, in: make([]reflect.Value, 1), argType: fv.Type().In(0)}
+	switch f := fv.Interface().(type) {
+	case func(int) bool:
+		c.pred = func(arg interface{}) bool { return f(arg.(int)) }
+	case func(int64) bool:
+		c.pred = func(arg interface{}) bool { return f(arg.(int64)) }
+	case func(float64) bool:
+		c.pred = func(arg interface{}) bool { return f(arg.(float64)) }
+	case func(string) bool:
+		c.pred = func(arg interface{}) bool { return f(arg.(string)) }
+	case func(int) int:
+		c.mapper = func(arg interface{}) interface{} { return f(arg.(int)) }
+	case func(int64) int64:
+		c.mapper = func(arg interface{}) interface{} { return f(arg.(int64)) }
+	case func(float64) float64:
+		c.mapper = func(arg interface{}) interface{} { return f(arg.(float64)) }
+	case func(string) string:
+		c.mapper = func(arg interface{}) interface{} { return f(arg.(string)) }
+	case func(int) string:
+		c.mapper = func(arg interface{}) interface{} { return f(arg.(int)) }
+	case func(string) int:
+		c.mapper = func(arg interface{}) interface{} { return f(arg.(string)) }
+	}
+	return c
+}
+
+// newCallers 为每个函数创建一个caller
+func newCallers(fvs []reflect.Value) []*caller {
+	callers := make([]*caller, len(fvs))
+	for i := 0; i < len(fvs); i++ {
+		callers[i] = newCaller(fvs[i])
+	}
+	return callers
+}
+
+// call1 以单个参数调用函数
+func (c *caller) call1(arg interface{}) []reflect.Value {
+	c.in[0] = valueOf(arg, c.argType)
+	return c.fv.Call(c.in)
+}
+
+// test 以单个参数调用 func(T) bool
+func (c *caller) test(arg interface{}) bool {
+	if c.pred != nil {
+		return c.pred(arg)
+	}
+	return c.call1(arg)[0].Bool()
+}
+
+// apply 以单个参数调用 func(T) O 或 func(T) (O, error)
+func (c *caller) apply(arg interface{}) (interface{}, error) {
+	if c.mapper != nil {
+		return c.mapper(arg), nil
+	}
+	op := c.call1(arg)
+	if len(op) == 2 && !op[1].IsNil() {
+		return nil, op[1].Interface().(error)
+	}
+	return op[0].Interface(), nil
+}
+
 func call(fv reflect.Value, args ...interface{}) []reflect.Value {
+	ft := fv.Type()
 	in := []reflect.Value{}
 	for i := 0; i < len(args); i++ {
-		in = append(in, reflect.ValueOf(args[i]))
+		var argType reflect.Type
+		if ft.IsVariadic() && i >= ft.NumIn()-1 {
+			argType = ft.In(ft.NumIn() - 1).Elem()
+		} else {
+			argType = ft.In(i)
+		}
+		in = append(in, valueOf(args[i], argType))
 	}
 	return fv.Call(in)
 }
+
+// valueOf 和reflect.ValueOf相同，但elem为nil时返回t类型的零值；
+// 元素类型为接口时nil元素会以nil interface{}的形式保存，直接reflect.ValueOf会得到无效的reflect.Value
+func valueOf(elem interface{}, t reflect.Type) reflect.Value {
+	if elem == nil {
+		return reflect.Zero(t)
+	}
+	return reflect.ValueOf(elem)
+}
@@ -1,9 +1,16 @@
 package streamv3
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"text/template"
+	"time"
 )
 
 type testUser struct {
@@ -123,6 +130,46 @@ func TestStreamerLimit(t *testing.T) {
 	assertEquals(t, result, expectedResult)
 }
 
+func TestStreamerSkipLast(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5}
+	result := []int{}
+	OfSlice(data).SkipLast(2).Scan(&result)
+	assertEquals(t, result, []int{1, 2, 3})
+	OfSlice(data).SkipLast(0).Scan(&result)
+	assertEquals(t, result, data)
+	OfSlice(data).SkipLast(10).Scan(&result)
+	assertEquals(t, result, []int{})
+
+	// 先去掉最后n条，再Offset，最后Limit，与调用的位置无关
+	OfSlice(data).SkipLast(1).Offset(1).Limit(2).Scan(&result)
+	assertEquals(t, result, []int{2, 3})
+	OfSlice(data).Limit(2).Skip(1).SkipLast(3).Scan(&result)
+	assertEquals(t, result, []int{2})
+	OfSlice(data).Filter(func(elem int) bool {
+		return elem%2 == 1
+	}).SkipLast(1).Map(func(elem int) int {
+		return elem * 10
+	}).Scan(&result)
+	assertEquals(t, result, []int{10, 30})
+	OfSlice(data).SkipLast(1).Sorted(func(a, b int) bool {
+		return a > b
+	}).Scan(&result)
+	assertEquals(t, result, []int{5, 4, 3, 2})
+
+	// 逐个执行时只多读取skipLast个元素
+	Iterate(1, func(x int) int {
+		return x + 1
+	}).SkipLast(3).Limit(2).Scan(&result)
+	assertEquals(t, result, []int{1, 2})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected SkipLast to panic on negative n")
+		}
+	}()
+	OfSlice(data).SkipLast(-1)
+}
+
 func TestStreamerSorted(t *testing.T) {
 	result := []int{}
 	streamer.Sorted(func(elem1, elem2 testUser) bool {
@@ -201,6 +248,36 @@ func TestStreamerToMap(t *testing.T) {
 	assertEquals(t, result, expectedResult)
 }
 
+func TestStreamerAssociate(t *testing.T) {
+	names := map[int]string{}
+	streamer.Associate(func(elem testUser) int {
+		return elem.ID
+	}, func(elem testUser) string {
+		return elem.Name
+	}, &names)
+	assertEquals(t, names, map[int]string{1: "zhangsan", 2: "lisi", 3: "wangwu", 4: "zhaoliu"})
+
+	// key重复时后面的元素覆盖前面的元素
+	var byAge map[int]int
+	streamer.Associate(func(elem testUser) int {
+		return elem.Age
+	}, func(elem testUser) int {
+		return elem.ID
+	}, &byAge)
+	assertEquals(t, byAge, map[int]int{15: 2, 20: 3, 25: 4})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected Associate to panic on mismatched result type")
+		}
+	}()
+	streamer.Associate(func(elem testUser) int {
+		return elem.ID
+	}, func(elem testUser) int {
+		return elem.Age
+	}, &names)
+}
+
 func TestStreamerFirst(t *testing.T) {
 	result := testUser{}
 	expectedResult := testData[3]
@@ -218,6 +295,20 @@ func TestStreamerFirst(t *testing.T) {
 	if exist {
 		t.Errorf("excepted not found, but return %v", result)
 	}
+
+	// 没有设置并行时取到第一个结果后即提前结束，设置了并行时仍然按批并行处理全部元素
+	first := 0
+	seen := make([]bool, 4)
+	mark := func(elem int) int {
+		seen[elem] = true
+		return elem
+	}
+	OfSlice([]int{0, 1, 2, 3}).Map(mark).First(&first)
+	assertEquals(t, seen, []bool{true, false, false, false})
+	seen = make([]bool, 4)
+	OfSlice([]int{0, 1, 2, 3}).ParallelUnbounded(2).Map(mark).First(&first)
+	assertEquals(t, seen, []bool{true, true, true, true})
+	assertEquals(t, first, 0)
 }
 
 func TestStreamerLast(t *testing.T) {
@@ -281,4 +372,1935 @@ func TestReduce(t *testing.T) {
 		expectedResult += testData[i].Age
 	}
 	assertEquals(t, result.Age, expectedResult)
+
+	sum := 100
+	OfSlice([]int{5}).Reduce(func(first, second int) int {
+		return first + second
+	}, &sum)
+	assertEquals(t, sum, 105)
+	OfSlice([]int{}).Reduce(func(first, second int) int {
+		return first + second
+	}, &sum)
+	assertEquals(t, sum, 105)
+}
+
+func TestStreamerWithParallelAll(t *testing.T) {
+	origin := OfSlice(testData).Filter(func(elem testUser) bool {
+		return elem.Age >= 15
+	}).Map(func(elem testUser) int {
+		return elem.ID
+	}).Sorted(func(id1, id2 int) bool {
+		return id1 > id2
+	})
+	cloned := origin.WithParallelAll(2)
+	for cur := cloned.(*SliceStreamer); cur != nil; cur = cur.lastStreamer {
+		assertEquals(t, cur.parallel, 2)
+	}
+	for cur := origin.(*SliceStreamer); cur != nil; cur = cur.lastStreamer {
+		assertEquals(t, cur.parallel, 1)
+	}
+
+	result := []int{}
+	cloned.Scan(&result)
+	assertEquals(t, result, []int{4, 3, 2, 1})
+}
+
+func TestStreamerParallelIsolation(t *testing.T) {
+	data := make([]int, 64)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	base := OfSlice(data).Filter(func(elem int) bool {
+		return elem%2 == 0
+	})
+	var running, maxRunning int64
+	serial := base.Parallel(1).Map(func(elem int) int {
+		cur := atomic.AddInt64(&running, 1)
+		if cur > atomic.LoadInt64(&maxRunning) {
+			atomic.StoreInt64(&maxRunning, cur)
+		}
+		time.Sleep(time.Microsecond)
+		atomic.AddInt64(&running, -1)
+		return elem * 10
+	})
+	parallel := base.ParallelUnbounded(8).Map(func(elem int) int {
+		return elem * 10
+	})
+
+	expected := []int{}
+	for i := 0; i < len(data); i += 2 {
+		expected = append(expected, i*10)
+	}
+	var wg sync.WaitGroup
+	results := make([][]int, 2)
+	for i, stream := range []SliceStream{serial, parallel} {
+		wg.Add(1)
+		go func(i int, stream SliceStream) {
+			defer wg.Done()
+			results[i] = []int{}
+			stream.Scan(&results[i])
+		}(i, stream)
+	}
+	wg.Wait()
+	assertEquals(t, results[0], expected)
+	assertEquals(t, results[1], expected)
+	assertEquals(t, maxRunning, int64(1))
+	assertEquals(t, base.(*SliceStreamer).parallel, 1)
+	assertEquals(t, serial.(*SliceStreamer).parallel, 1)
+	assertEquals(t, parallel.(*SliceStreamer).parallel, 8)
+}
+
+func TestStreamerScanCollectErrors(t *testing.T) {
+	result := []int{}
+	errs := []error{}
+	OfSlice([]string{"1", "a", "3", "b"}).Map(func(elem string) (int, error) {
+		return strconv.Atoi(elem)
+	}).ScanCollectErrors(&result, &errs)
+	assertEquals(t, result, []int{1, 3})
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, but return %v", errs)
+	}
+	assertEquals(t, strings.Contains(errs[0].Error(), `"a"`), true)
+	assertEquals(t, strings.Contains(errs[1].Error(), `"b"`), true)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected Scan to panic on mapper error")
+		}
+	}()
+	OfSlice([]string{"1", "a"}).Map(func(elem string) (int, error) {
+		return strconv.Atoi(elem)
+	}).Scan(&result)
+}
+
+func TestStreamerIsSorted(t *testing.T) {
+	sortedByID := streamer.IsSorted(func(elem1, elem2 testUser) bool {
+		return elem1.ID < elem2.ID
+	})
+	assertEquals(t, sortedByID, true)
+
+	sortedByName := streamer.IsSorted(func(elem1, elem2 testUser) bool {
+		return elem1.Name < elem2.Name
+	})
+	assertEquals(t, sortedByName, false)
+}
+
+func TestStreamerScanAndRelease(t *testing.T) {
+	source := OfSlice(testData)
+	result := []int{}
+	s := source.Map(func(elem testUser) int {
+		return elem.ID
+	})
+	s.ScanAndRelease(&result)
+	assertEquals(t, result, []int{1, 2, 3, 4})
+
+	getter := source.(*SliceStreamer).dataGetter.(*sliceGetter)
+	if getter.data != nil {
+		t.Errorf("expected source data to be released, but still hold %v", getter.data)
+	}
+	s.Scan(&result)
+	assertEquals(t, result, []int{})
+}
+
+func TestStreamerDistinct(t *testing.T) {
+	result := []int{}
+	streamer.Map(func(elem testUser) int {
+		return elem.Age
+	}).Distinct().Scan(&result)
+	assertEquals(t, result, []int{15, 20, 25})
+
+	mixed := []interface{}{}
+	source := []interface{}{[]int{1}, []int{1}, 1, nil, map[string]int{"a": 1}, 1, nil, map[string]int{"a": 1}, []int{2}}
+	OfSlice(source).Distinct().Scan(&mixed)
+	expected := []interface{}{[]int{1}, 1, nil, map[string]int{"a": 1}, []int{2}}
+	assertEquals(t, mixed, expected)
+	OfSlice(source).Distinct().Limit(2).Scan(&mixed)
+	assertEquals(t, mixed, expected[:2])
+
+	// 结构体类型可比较，但interface字段中保存的是不可比较的值
+	type tagged struct {
+		Tag interface{}
+	}
+	nested := []tagged{}
+	OfSlice([]tagged{{[]int{1}}, {1}, {[]int{1}}, {1}, {[]int{2}}}).Distinct().Scan(&nested)
+	assertEquals(t, nested, []tagged{{[]int{1}}, {1}, {[]int{2}}})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected Distinct to panic on uncomparable type")
+		}
+	}()
+	OfSlice([][]int{{1}, {2}}).Distinct()
+}
+
+func TestStreamerTumblingAggregate(t *testing.T) {
+	result := []int{}
+	streamer.TumblingAggregate(2, func(acc int, elem testUser) int {
+		return acc + elem.Age
+	}, 0, &result)
+	assertEquals(t, result, []int{30, 45})
+
+	streamer.TumblingAggregate(3, func(acc int, elem testUser) int {
+		return acc + elem.Age
+	}, 100, &result)
+	assertEquals(t, result, []int{150, 125})
+}
+
+func TestStreamerShard(t *testing.T) {
+	result := [][]int{}
+	OfSlice([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}).Shard(3, &result)
+	assertEquals(t, result, [][]int{{0, 1, 2, 3}, {4, 5, 6}, {7, 8, 9}})
+
+	OfSlice([]int{0, 1}).Shard(3, &result)
+	assertEquals(t, result, [][]int{{0}, {1}, {}})
+}
+
+func TestStreamerPeek(t *testing.T) {
+	peeked := map[int]int{}
+	result := []int{}
+	streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 15
+	}).Peek(func(elem testUser) {
+		peeked[elem.ID]++
+	}).Map(func(elem testUser) int {
+		return elem.ID
+	}).Limit(1).Scan(&result)
+	assertEquals(t, result, []int{3})
+	// Limit会提前结束，只有实际被处理的元素才会到达Peek
+	assertEquals(t, peeked, map[int]int{3: 1})
+
+	peeked = map[int]int{}
+	streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 15
+	}).Peek(func(elem testUser) {
+		peeked[elem.ID]++
+	}).Map(func(elem testUser) int {
+		return elem.ID
+	}).Scan(&result)
+	assertEquals(t, result, []int{3, 4})
+	assertEquals(t, peeked, map[int]int{3: 1, 4: 1})
+}
+
+func TestStreamerTakeUntil(t *testing.T) {
+	result := []string{}
+	streamer.TakeUntil(func(elem testUser) bool {
+		return elem.Name == "wangwu"
+	}).Map(func(elem testUser) string {
+		return elem.Name
+	}).Scan(&result)
+	assertEquals(t, result, []string{"zhangsan", "lisi"})
+}
+
+func TestStreamerReverse(t *testing.T) {
+	result := []int{}
+	OfSlice([]int{5, 3, 1, 4, 2}).Sorted(func(item1, item2 int) bool {
+		return item1 < item2
+	}).Reverse().Limit(3).Scan(&result)
+	assertEquals(t, result, []int{5, 4, 3})
+
+	streamer.Map(func(elem testUser) int {
+		return elem.ID
+	}).Reverse().Offset(1).Scan(&result)
+	assertEquals(t, result, []int{3, 2, 1})
+}
+
+func TestStreamerToIndexMap(t *testing.T) {
+	result := map[int]testUser{}
+	streamer.ToIndexMap(&result)
+	assertEquals(t, len(result), 4)
+	assertEquals(t, result[2].ID, 3)
+}
+
+func TestStreamerWithWorkStealing(t *testing.T) {
+	data := []int{}
+	for i := 0; i < 100; i++ {
+		data = append(data, i)
+	}
+	result := []int{}
+	OfSlice(data).Parallel(8).WithWorkStealing().Filter(func(item int) bool {
+		return item%2 == 0
+	}).Map(func(item int) int {
+		return item * 10
+	}).FlatMap(func(item int) []int {
+		return []int{item, item + 1}
+	}).Scan(&result)
+
+	expectedResult := []int{}
+	for i := 0; i < 100; i += 2 {
+		expectedResult = append(expectedResult, i*10, i*10+1)
+	}
+	assertEquals(t, result, expectedResult)
+}
+
+// skewedData 前1/4的元素处理耗时远大于其他元素
+func skewedData() []int {
+	data := make([]int, 64)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	return data
+}
+
+func skewedMapper(item int) int {
+	if item < 16 {
+		time.Sleep(time.Millisecond)
+	}
+	return item
+}
+
+func BenchmarkStreamerMapSkewed(b *testing.B) {
+	data := skewedData()
+	for i := 0; i < b.N; i++ {
+		OfSlice(data).Parallel(4).Map(skewedMapper).Count()
+	}
+}
+
+func BenchmarkStreamerMapSkewedWorkStealing(b *testing.B) {
+	data := skewedData()
+	for i := 0; i < b.N; i++ {
+		OfSlice(data).Parallel(4).WithWorkStealing().Map(skewedMapper).Count()
+	}
+}
+
+func TestStreamerSum(t *testing.T) {
+	sum := 0
+	streamer.Map(func(elem testUser) int {
+		return elem.Age
+	}).Sum(&sum)
+	assertEquals(t, sum, 75)
+
+	floatSum := 0.0
+	OfSlice([]float64{0.5, 1.5, 2}).Sum(&floatSum)
+	assertEquals(t, floatSum, 4.0)
+}
+
+func TestStreamerAverage(t *testing.T) {
+	avg := 0.0
+	streamer.Map(func(elem testUser) int {
+		return elem.Age
+	}).Average(&avg)
+	assertEquals(t, avg, 18.75)
+
+	avg = 1
+	OfSlice([]int{}).Average(&avg)
+	assertEquals(t, avg, 0.0)
+}
+
+func TestStreamerSumByAverageBy(t *testing.T) {
+	ageExtractor := func(elem testUser) int {
+		return elem.Age
+	}
+	sum := 0
+	streamer.SumBy(ageExtractor, &sum)
+	assertEquals(t, sum, 75)
+	floatSum := 0.0
+	streamer.SumBy(func(elem testUser) float64 {
+		return float64(elem.ID) / 2
+	}, &floatSum)
+	assertEquals(t, floatSum, 5.0)
+
+	avg := 0.0
+	streamer.AverageBy(ageExtractor, &avg)
+	assertEquals(t, avg, 18.75)
+	avg = 1
+	streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 100
+	}).AverageBy(ageExtractor, &avg)
+	assertEquals(t, avg, 0.0)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected SumBy to panic on mismatched result type")
+		}
+	}()
+	streamer.SumBy(ageExtractor, &floatSum)
+}
+
+func TestStreamerMinMax(t *testing.T) {
+	byAge := func(elem1, elem2 testUser) bool {
+		return elem1.Age < elem2.Age
+	}
+	result := testUser{}
+	exist := streamer.Min(byAge, &result)
+	assertEquals(t, exist, true)
+	assertEquals(t, result, testData[0])
+
+	exist = streamer.Max(byAge, &result)
+	assertEquals(t, exist, true)
+	assertEquals(t, result, testData[3])
+
+	result = testUser{}
+	exist = streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 100
+	}).Max(byAge, &result)
+	assertEquals(t, exist, false)
+	assertEquals(t, result, testUser{})
+}
+
+func TestStreamerMinByMaxBy(t *testing.T) {
+	ageKeyer := func(elem testUser) int {
+		return elem.Age
+	}
+	nameKeyer := func(elem testUser) string {
+		return elem.Name
+	}
+	result := testUser{}
+	// key相同时取最先出现的元素
+	exist := streamer.MinBy(ageKeyer, &result)
+	assertEquals(t, exist, true)
+	assertEquals(t, result, testData[0])
+	exist = streamer.MaxBy(ageKeyer, &result)
+	assertEquals(t, exist, true)
+	assertEquals(t, result, testData[3])
+	streamer.MinBy(nameKeyer, &result)
+	assertEquals(t, result, testData[1])
+	streamer.MaxBy(nameKeyer, &result)
+	assertEquals(t, result, testData[3])
+
+	result = testUser{}
+	exist = streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 100
+	}).MinBy(ageKeyer, &result)
+	assertEquals(t, exist, false)
+	assertEquals(t, result, testUser{})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected MaxBy to panic on unordered key")
+		}
+	}()
+	streamer.MaxBy(func(elem testUser) bool {
+		return elem.Age > 15
+	}, &result)
+}
+
+func TestStreamerRender(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := streamer.Render(buf, template.Must(template.New("user").Parse("{{.Name}};")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, buf.String(), "zhangsan;lisi;wangwu;zhaoliu;")
+
+	buf.Reset()
+	err = streamer.Limit(2).RenderAll(buf, template.Must(template.New("users").Parse("{{len .}}:{{range .}}{{.ID}}{{end}}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, buf.String(), "2:12")
+}
+
+func TestStreamerMatch(t *testing.T) {
+	adult := func(elem testUser) bool {
+		return elem.Age >= 18
+	}
+	assertEquals(t, streamer.AnyMatch(adult), true)
+	assertEquals(t, streamer.AllMatch(adult), false)
+	assertEquals(t, streamer.NoneMatch(adult), false)
+
+	calls := 0
+	streamer.AnyMatch(func(elem testUser) bool {
+		calls++
+		return elem.ID == 2
+	})
+	assertEquals(t, calls, 2)
+
+	empty := streamer.Filter(func(elem testUser) bool {
+		return false
+	})
+	assertEquals(t, empty.AnyMatch(adult), false)
+	assertEquals(t, empty.AllMatch(adult), true)
+	assertEquals(t, empty.NoneMatch(adult), true)
+}
+
+func TestStreamerGroupByStream(t *testing.T) {
+	result := []Group{}
+	streamer.GroupByStream(func(elem testUser) int {
+		return elem.Age
+	}).Sorted(func(group1, group2 Group) bool {
+		return len(group1.Items) > len(group2.Items)
+	}).Limit(1).Scan(&result)
+	expectedResult := []Group{
+		{
+			Key:   15,
+			Items: []interface{}{testData[0], testData[1]},
+		},
+	}
+	assertEquals(t, result, expectedResult)
+
+	count := streamer.GroupByStream(func(elem testUser) int {
+		return elem.Age
+	}).Count()
+	assertEquals(t, count, 3)
+}
+
+func TestStreamerPartition(t *testing.T) {
+	adults := []testUser{}
+	minors := []testUser{}
+	streamer.Partition(func(elem testUser) bool {
+		return elem.Age >= 18
+	}, &adults, &minors)
+	assertEquals(t, adults, testData[2:])
+	assertEquals(t, minors, testData[:2])
+}
+
+func TestStreamerScanAs(t *testing.T) {
+	names := []string{}
+	streamer.ScanAs(func(elem testUser) string {
+		return elem.Name
+	}, &names)
+	assertEquals(t, names, []string{"zhangsan", "lisi", "wangwu", "zhaoliu"})
+
+	ids := []int{}
+	streamer.ScanAs(func(elem testUser) (int, bool) {
+		return elem.ID, elem.Age >= 18
+	}, &ids)
+	assertEquals(t, ids, []int{3, 4})
+}
+
+func TestStreamerJoining(t *testing.T) {
+	names := streamer.Map(func(elem testUser) string {
+		return elem.Name
+	})
+	result := ""
+	names.Joining(",", &result)
+	assertEquals(t, result, "zhangsan,lisi,wangwu,zhaoliu")
+
+	names.Limit(2).JoiningWith(", ", "[", "]", &result)
+	assertEquals(t, result, "[zhangsan, lisi]")
+
+	OfSlice([]string{}).Joining(",", &result)
+	assertEquals(t, result, "")
+}
+
+func TestStreamerWeightedAverage(t *testing.T) {
+	avg := 0.0
+	ok := streamer.WeightedAverage(func(elem testUser) int {
+		return elem.Age
+	}, func(elem testUser) int {
+		return elem.ID
+	}, &avg)
+	assertEquals(t, ok, true)
+	assertEquals(t, avg, 20.5)
+
+	ok = streamer.WeightedAverage(func(elem testUser) int {
+		return elem.Age
+	}, func(elem testUser) float64 {
+		return 0
+	}, &avg)
+	assertEquals(t, ok, false)
+}
+
+// 需要配合 go test -race 运行
+func TestStreamerGroupByParallel(t *testing.T) {
+	data := make([]int, 10000)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	result := map[int][]int{}
+	OfSlice(data).Parallel(8).GroupBy(func(item int) int {
+		return item % 7
+	}, &result)
+	assertEquals(t, len(result), 7)
+	total := 0
+	for _, items := range result {
+		total += len(items)
+	}
+	assertEquals(t, total, len(data))
+}
+
+func TestStreamerGroupByParallelOrder(t *testing.T) {
+	data := make([]int, 10001)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	for _, parallel := range []int{1, 3, 8} {
+		result := map[int][]int{}
+		OfSlice(data).ParallelUnbounded(parallel).GroupBy(func(item int) int {
+			return item % 7
+		}, &result)
+		for key, items := range result {
+			for i := 0; i < len(items); i++ {
+				if items[i] != key+i*7 {
+					t.Fatalf("parallel %d: group %d is out of order at %d: %d", parallel, key, i, items[i])
+				}
+			}
+		}
+	}
+}
+
+func TestStreamerParallelOrder(t *testing.T) {
+	data := make([]int, 10001)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	// 让前面的元素处理得更慢，使靠前的goroutine晚于靠后的goroutine结束
+	slow := func(elem int) {
+		if elem < 1000 && elem%100 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	expectedFiltered := []int{}
+	expectedMapped := []int{}
+	expectedFlat := []int{}
+	for i := 0; i < len(data); i++ {
+		if i%3 != 0 {
+			expectedFiltered = append(expectedFiltered, i)
+		}
+		expectedMapped = append(expectedMapped, i*2)
+		expectedFlat = append(expectedFlat, i, -i)
+	}
+	streams := map[string]SliceStream{
+		"Parallel":          OfSlice(data).Parallel(8),
+		"ParallelUnbounded": OfSlice(data).ParallelUnbounded(8),
+		"WorkStealing":      OfSlice(data).ParallelUnbounded(8).WithWorkStealing(),
+		"Pool":              OfSlice(data).WithPool(4).ParallelUnbounded(8),
+	}
+	for name, stream := range streams {
+		filtered := []int{}
+		stream.Filter(func(elem int) bool {
+			slow(elem)
+			return elem%3 != 0
+		}).Scan(&filtered)
+		if !reflect.DeepEqual(filtered, expectedFiltered) {
+			t.Errorf("%s: Filter didn't preserve input order", name)
+		}
+		mapped := []int{}
+		stream.Map(func(elem int) int {
+			slow(elem)
+			return elem * 2
+		}).Scan(&mapped)
+		if !reflect.DeepEqual(mapped, expectedMapped) {
+			t.Errorf("%s: Map didn't preserve input order", name)
+		}
+		flat := []int{}
+		stream.FlatMap(func(elem int) []int {
+			slow(elem)
+			return []int{elem, -elem}
+		}).Scan(&flat)
+		if !reflect.DeepEqual(flat, expectedFlat) {
+			t.Errorf("%s: FlatMap didn't preserve input order", name)
+		}
+	}
+}
+
+func TestStreamerScanN(t *testing.T) {
+	buf := make([]testUser, 2)
+	n := streamer.ScanN(&buf)
+	assertEquals(t, n, 2)
+	assertEquals(t, buf, testData[:2])
+
+	arr := [8]int{}
+	n = streamer.Map(func(elem testUser) int {
+		return elem.ID
+	}).ScanN(&arr)
+	assertEquals(t, n, 4)
+	assertEquals(t, arr, [8]int{1, 2, 3, 4})
+}
+
+// 需要配合 go test -race 运行
+func TestStreamerToMapParallel(t *testing.T) {
+	data := make([]int, 10000)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	result := map[int]int{}
+	OfSlice(data).Parallel(8).ToMap(func(item int) int {
+		return item
+	}, &result)
+	assertEquals(t, len(result), len(data))
+	assertEquals(t, result[len(data)-1], len(data)-1)
+}
+
+func TestStreamerCycleDetection(t *testing.T) {
+	head := OfSlice(testData).(*SliceStreamer)
+	tail := head.Filter(func(elem testUser) bool {
+		return true
+	}).(*SliceStreamer)
+	head.lastStreamer = tail
+
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || err.Error() != "cycle detected in stream chain" {
+			t.Errorf("expected cycle detected panic, but return %v", r)
+		}
+	}()
+	tail.Count()
+}
+
+func TestStreamerSortedStable(t *testing.T) {
+	data := make([]testUser, 100)
+	for i := 0; i < len(data); i++ {
+		data[i] = testUser{ID: i, Age: i % 3}
+	}
+	result := []testUser{}
+	OfSlice(data).Sorted(func(elem1, elem2 testUser) bool {
+		return elem1.Age < elem2.Age
+	}).Scan(&result)
+	for i := 1; i < len(result); i++ {
+		if result[i-1].Age == result[i].Age && result[i-1].ID > result[i].ID {
+			t.Fatalf("expected stable order, but %v is before %v", result[i-1], result[i])
+		}
+	}
+}
+
+func TestStreamerSortedParallel(t *testing.T) {
+	data := make([]testUser, parallelSortThreshold*3+7)
+	for i := 0; i < len(data); i++ {
+		data[i] = testUser{ID: i, Age: (i * 7919) % 100}
+	}
+	byAge := func(elem1, elem2 testUser) bool {
+		return elem1.Age < elem2.Age
+	}
+	expected := []testUser{}
+	OfSlice(data).Sorted(byAge).Scan(&expected)
+	for _, parallel := range []int{2, 3, 8} {
+		result := []testUser{}
+		OfSlice(data).ParallelUnbounded(parallel).Sorted(byAge).Scan(&result)
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("parallel %d: expected the same stable order as serial sort", parallel)
+		}
+	}
+}
+
+func TestStreamerScanCopy(t *testing.T) {
+	type team struct {
+		Name    string
+		Members []string
+	}
+	source := []*team{
+		{Name: "a", Members: []string{"zhangsan", "lisi"}},
+		{Name: "b", Members: []string{"wangwu"}},
+	}
+	result := []*team{}
+	OfSlice(source).ScanCopy(&result)
+	assertEquals(t, result, source)
+
+	result[0].Name = "changed"
+	result[0].Members[0] = "changed"
+	assertEquals(t, source[0].Name, "a")
+	assertEquals(t, source[0].Members[0], "zhangsan")
+
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "a"}
+	loop.Next = &node{Name: "b", Next: loop}
+	loopResult := []*node{}
+	OfSlice([]*node{loop, loop.Next}).ScanCopy(&loopResult)
+	assertEquals(t, len(loopResult), 2)
+	if loopResult[0] == loop || loopResult[0].Next.Next != loopResult[0] || loopResult[0].Next != loopResult[1] {
+		t.Fatalf("expected the copy to keep the same cycle, got %v", loopResult)
+	}
+	assertEquals(t, loopResult[1].Name, "b")
+}
+
+func TestStreamerHighParallelSmallData(t *testing.T) {
+	pipeline := func(stream SliceStream) SliceStream {
+		return stream.Filter(func(elem int) bool {
+			return elem > 0
+		}).Map(func(elem int) int {
+			return elem * 2
+		}).FlatMap(func(elem int) []int {
+			return []int{elem, elem}
+		})
+	}
+
+	empty := []int{}
+	pipeline(OfSlice([]int{}).Parallel(8)).Scan(&empty)
+	assertEquals(t, empty, []int{})
+	pipeline(OfSlice([]int{}).Parallel(8).WithWorkStealing()).Scan(&empty)
+	assertEquals(t, empty, []int{})
+
+	single := []int{}
+	pipeline(OfSlice([]int{1}).Parallel(8)).Scan(&single)
+	assertEquals(t, single, []int{2, 2})
+	pipeline(OfSlice([]int{1}).Parallel(8).WithWorkStealing()).Scan(&single)
+	assertEquals(t, single, []int{2, 2})
+}
+
+func TestStreamerCrossJoin(t *testing.T) {
+	result := []string{}
+	OfSlice([]int{1, 2}).CrossJoin(OfSlice([]string{"a", "b", "c"}), func(num int, letter string) string {
+		return strconv.Itoa(num) + letter
+	}, &result)
+	assertEquals(t, result, []string{"1a", "1b", "1c", "2a", "2b", "2c"})
+}
+
+func TestStreamerEqual(t *testing.T) {
+	assertEquals(t, streamer.Equal(OfSlice(testData)), true)
+	assertEquals(t, streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 15
+	}).Equal(OfSlice(testData[2:])), true)
+	assertEquals(t, streamer.Equal(streamer.Limit(3)), false)
+	assertEquals(t, streamer.Equal(streamer.Reverse()), false)
+	assertEquals(t, OfSlice([]int{}).Equal(OfSlice([]int{})), true)
+	// 元素类型不同
+	assertEquals(t, OfSlice([]int{1, 2}).Equal(OfSlice([]int64{1, 2})), false)
+	// 两条链的结果相同
+	ages := OfSlice(testData).Map(func(elem testUser) int {
+		return elem.Age
+	}).Distinct()
+	assertEquals(t, ages.Parallel(2).Equal(OfSlice([]int{15, 20, 25})), true)
+}
+
+func TestStreamerFlatMapAfterMap(t *testing.T) {
+	mapped := OfSlice([]int{1, 2, 3}).Map(func(elem int) int {
+		return elem * 10
+	}).Filter(func(elem int) bool {
+		return elem > 10
+	})
+	// 中间节点没有dataGetter，flatMapFunc为空时应原样返回传入的数据
+	data := []interface{}{20, 30}
+	assertEquals(t, mapped.(*SliceStreamer).flatMap(data), data)
+
+	result := []int{}
+	mapped.FlatMap(func(elem int) []int {
+		return []int{elem, elem + 1}
+	}).Scan(&result)
+	assertEquals(t, result, []int{20, 21, 30, 31})
+}
+
+func TestStreamerForeachIndexed(t *testing.T) {
+	result := []string{}
+	OfSlice(testData).Parallel(2).Map(func(elem testUser) string {
+		return elem.Name
+	}).ForeachIndexed(func(index int, name string) {
+		result = append(result, strconv.Itoa(index)+":"+name)
+	})
+	assertEquals(t, result, []string{"0:zhangsan", "1:lisi", "2:wangwu", "3:zhaoliu"})
+}
+
+func TestStreamerPercentByKey(t *testing.T) {
+	result := map[int]float64{}
+	streamer.PercentByKey(func(elem testUser) int {
+		return elem.Age
+	}, &result)
+	assertEquals(t, result, map[int]float64{15: 0.5, 20: 0.25, 25: 0.25})
+}
+
+func TestStreamerChunk(t *testing.T) {
+	result := [][]int{}
+	OfSlice([]int{1, 2, 3, 4, 5}).Chunk(2).Scan(&result)
+	assertEquals(t, result, [][]int{{1, 2}, {3, 4}, {5}})
+
+	sizes := []int{}
+	OfSlice([]int{1, 2, 3, 4, 5}).Chunk(3).Map(func(elem []int) int {
+		return len(elem)
+	}).Scan(&sizes)
+	assertEquals(t, sizes, []int{3, 2})
+}
+
+func TestStreamerMapEmit(t *testing.T) {
+	result := []string{}
+	OfSlice(testData[:2]).Parallel(2).MapEmit(func(elem testUser, emit func(string)) {
+		for _, c := range elem.Email {
+			emit(string(c))
+		}
+	}).Scan(&result)
+	expectedResult := []string{}
+	for _, c := range testData[0].Email + testData[1].Email {
+		expectedResult = append(expectedResult, string(c))
+	}
+	assertEquals(t, result, expectedResult)
+
+	nothing := []int{}
+	OfSlice([]int{1, 2, 3}).MapEmit(func(elem int, emit func(int)) {}).Scan(&nothing)
+	assertEquals(t, nothing, []int{})
+}
+
+func BenchmarkStreamerFlatMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		OfSlice(testData).FlatMap(func(elem testUser) []byte {
+			return []byte(elem.Email)
+		}).Count()
+	}
+}
+
+func BenchmarkStreamerMapEmit(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		OfSlice(testData).MapEmit(func(elem testUser, emit func(byte)) {
+			for j := 0; j < len(elem.Email); j++ {
+				emit(elem.Email[j])
+			}
+		}).Count()
+	}
+}
+
+func TestStreamerWindow(t *testing.T) {
+	result := [][]int{}
+	OfSlice([]int{1, 2, 3, 4, 5}).Window(3, 1).Scan(&result)
+	assertEquals(t, result, [][]int{{1, 2, 3}, {2, 3, 4}, {3, 4, 5}})
+
+	OfSlice([]int{1, 2, 3, 4, 5, 6}).Window(2, 3).Scan(&result)
+	assertEquals(t, result, [][]int{{1, 2}, {4, 5}})
+
+	OfSlice([]int{1, 2}).Window(3, 1).Scan(&result)
+	assertEquals(t, result, [][]int{})
+
+	OfSlice([]int{1, 2, 3, 4, 5}).Window(2, 2).Scan(&result)
+	assertEquals(t, result, [][]int{{1, 2}, {3, 4}})
+
+	averages := []int{}
+	OfSlice([]int{1, 2, 3, 4, 5}).Window(3, 1).Map(func(elem []int) int {
+		return (elem[0] + elem[1] + elem[2]) / 3
+	}).Scan(&averages)
+	assertEquals(t, averages, []int{2, 3, 4})
+}
+
+func TestStreamerFindDuplicates(t *testing.T) {
+	data := append([]testUser{}, testData...)
+	data = append(data, testUser{ID: 2, Name: "duplicated"})
+	result := []testUser{}
+	OfSlice(data).FindDuplicates(func(elem testUser) int {
+		return elem.ID
+	}, &result)
+	assertEquals(t, result, []testUser{testData[1], data[4]})
+
+	streamer.FindDuplicates(func(elem testUser) int {
+		return elem.ID
+	}, &result)
+	assertEquals(t, result, []testUser{})
+}
+
+func TestStreamerConcat(t *testing.T) {
+	young := OfSlice(testData).Filter(func(elem testUser) bool {
+		return elem.Age < 18
+	})
+	old := OfSlice(testData).Filter(func(elem testUser) bool {
+		return elem.Age >= 18
+	}).Limit(1)
+	result := []int{}
+	old.Concat(young).Map(func(elem testUser) int {
+		return elem.ID
+	}).Scan(&result)
+	assertEquals(t, result, []int{3, 1, 2})
+}
+
+func largeStream() SliceStream {
+	data := make([]int, 1000000)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	return OfSlice(data)
+}
+
+// BenchmarkStreamerScanAppend 逐个Append的写法，作为Scan的对照
+func BenchmarkStreamerScanAppend(b *testing.B) {
+	s := largeStream().(*SliceStreamer)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := []int{}
+		val := reflect.ValueOf(&result).Elem()
+		for _, elem := range s.scan() {
+			val.Set(reflect.Append(val, reflect.ValueOf(elem)))
+		}
+	}
+}
+
+func BenchmarkStreamerScan(b *testing.B) {
+	s := largeStream()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := []int{}
+		s.Scan(&result)
+	}
+}
+
+func TestStreamerScanReuse(t *testing.T) {
+	result := make([]int, 5, 10)
+	OfSlice([]int{1, 2}).Scan(&result)
+	assertEquals(t, result, []int{1, 2})
+	assertEquals(t, cap(result), 10)
+
+	OfSlice([]int{}).Scan(&result)
+	assertEquals(t, result, []int{})
+}
+
+func TestStreamerFold(t *testing.T) {
+	sum := func(acc, item int) int {
+		return acc + item
+	}
+	identity := 100
+	result := 0
+	OfSlice([]int{1, 2, 3}).Fold(&identity, sum, &result)
+	assertEquals(t, result, 106)
+
+	// 只有一个元素时也从identity开始聚合
+	OfSlice([]int{1}).Fold(&identity, sum, &result)
+	assertEquals(t, result, 101)
+
+	OfSlice([]int{}).Fold(&identity, sum, &result)
+	assertEquals(t, result, 100)
+}
+
+func TestStreamerReport(t *testing.T) {
+	result := []ReportRow{}
+	OfSlice(testData).Report(func(elem testUser) int {
+		return elem.Age
+	}, map[string]interface{}{
+		"count": func(items []testUser) int {
+			return len(items)
+		},
+		"sumID": func(items []testUser) int {
+			sum := 0
+			for _, item := range items {
+				sum += item.ID
+			}
+			return sum
+		},
+	}, &result)
+	expectedResult := []ReportRow{
+		{Key: 15, Values: map[string]interface{}{"count": 2, "sumID": 3}},
+		{Key: 20, Values: map[string]interface{}{"count": 1, "sumID": 3}},
+		{Key: 25, Values: map[string]interface{}{"count": 1, "sumID": 4}},
+	}
+	assertEquals(t, result, expectedResult)
+}
+
+func TestStreamerCollect(t *testing.T) {
+	counter := map[int]int{}
+	streamer.Collect(func() map[int]int {
+		return map[int]int{}
+	}, func(acc map[int]int, elem testUser) {
+		acc[elem.Age]++
+	}, &counter)
+	assertEquals(t, counter, map[int]int{15: 2, 20: 1, 25: 1})
+
+	var builder *strings.Builder
+	streamer.Collect(func() *strings.Builder {
+		return &strings.Builder{}
+	}, func(acc *strings.Builder, elem testUser) {
+		acc.WriteString(elem.Name[:1])
+	}, &builder)
+	assertEquals(t, builder.String(), "zlwz")
+}
+
+func TestStreamerToSet(t *testing.T) {
+	var result map[int]struct{}
+	streamer.Map(func(elem testUser) int {
+		return elem.Age
+	}).ToSet(&result)
+	assertEquals(t, result, map[int]struct{}{15: {}, 20: {}, 25: {}})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for non-comparable type")
+		}
+	}()
+	slices := map[string]struct{}{}
+	OfSlice([][]int{{1}}).ToSet(&slices)
+}
+
+func TestStreamerCountBy(t *testing.T) {
+	result := map[int]int{}
+	OfSlice(testData).Parallel(2).CountBy(func(elem testUser) int {
+		return elem.Age
+	}, &result)
+	assertEquals(t, result, map[int]int{15: 2, 20: 1, 25: 1})
+}
+
+func TestStreamerGroupByReduce(t *testing.T) {
+	result := map[int]testUser{}
+	streamer.GroupByReduce(func(elem testUser) int {
+		return elem.Age
+	}, func(a, b testUser) testUser {
+		a.ID += b.ID
+		a.Name += "," + b.Name
+		return a
+	}, &result)
+	// 按元素顺序聚合，只有一个元素的分组保持原样
+	assertEquals(t, result, map[int]testUser{
+		15: {ID: 3, Name: "zhangsan,lisi", Age: 15, Email: "zhangsan@xxx.com"},
+		20: testData[2],
+		25: testData[3],
+	})
+
+	sums := map[bool]int{}
+	OfSlice([]int{1, 2, 3, 4, 5}).GroupByReduce(func(elem int) bool {
+		return elem%2 == 0
+	}, func(a, b int) int {
+		return a + b
+	}, &sums)
+	assertEquals(t, sums, map[bool]int{false: 9, true: 6})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected GroupByReduce to panic on mismatched accumulator")
+		}
+	}()
+	OfSlice([]int{1, 2}).GroupByReduce(func(elem int) int {
+		return elem
+	}, func(a, b int) int64 {
+		return int64(a + b)
+	}, &sums)
+}
+
+func TestStreamerBatchChannel(t *testing.T) {
+	data := make([]int, 10)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	batches := OfSlice(data).BatchChannel(3)
+	done := make(chan []int)
+	go func() {
+		result := []int{}
+		for batch := range batches {
+			result = append(result, batch.([]int)...)
+		}
+		done <- result
+	}()
+	assertEquals(t, <-done, data)
+
+	sizes := []int{}
+	for batch := range OfSlice(data).BatchChannel(4) {
+		sizes = append(sizes, len(batch.([]int)))
+	}
+	assertEquals(t, sizes, []int{4, 4, 2})
+
+	// 上游还没有结束时，已经攒够的批次就会被发送
+	source := make(chan int)
+	go func() {
+		for i := 0; i < 3; i++ {
+			source <- i
+		}
+	}()
+	batches = OfChannel(source).Map(func(elem int) int {
+		return elem * 10
+	}).BatchChannel(3)
+	select {
+	case batch := <-batches:
+		assertEquals(t, batch, []int{0, 10, 20})
+	case <-time.After(time.Second):
+		t.Fatal("expected the first batch before the source is closed")
+	}
+	source <- 3
+	close(source)
+	assertEquals(t, <-batches, []int{30})
+	_, ok := <-batches
+	assertEquals(t, ok, false)
+
+	sorted := []int{}
+	for batch := range OfSlice([]int{3, 1, 2}).Sorted(func(a, b int) bool {
+		return a < b
+	}).BatchChannel(2) {
+		sorted = append(sorted, batch.([]int)...)
+	}
+	assertEquals(t, sorted, []int{1, 2, 3})
+
+	// 逐个元素执行时mapper返回的error以*PanicError作为最后一个元素发送
+	mapErr := errors.New("bad elem")
+	mapper := func(elem int) (int, error) {
+		if elem == 3 {
+			return 0, mapErr
+		}
+		return elem, nil
+	}
+	received := []interface{}{}
+	for batch := range OfSlice([]int{1, 2, 3, 4}).Map(mapper).BatchChannel(2) {
+		received = append(received, batch)
+	}
+	assertEquals(t, len(received), 2)
+	assertEquals(t, received[0], []int{1, 2})
+	panicErr, ok := received[1].(*PanicError)
+	if !ok || !errors.Is(panicErr, mapErr) {
+		t.Fatalf("expected a *PanicError wrapping %v, got %v", mapErr, received[1])
+	}
+
+	// 需要全部数据的链在调用者的goroutine中执行，可以被Try捕获
+	err := Try(func() {
+		OfSlice([]int{1, 2, 3, 4}).Map(mapper).Sorted(func(a, b int) bool {
+			return a < b
+		}).BatchChannel(2)
+	})
+	if !errors.Is(err, mapErr) {
+		t.Fatalf("expected Try to catch %v, got %v", mapErr, err)
+	}
+}
+
+func TestStreamerNthBy(t *testing.T) {
+	ageLess := func(elem1, elem2 testUser) bool {
+		return elem1.Age < elem2.Age
+	}
+	result := testUser{}
+	// 年龄排序后为 15 15 20 25，第2小（下标1）的年龄为15
+	exist := streamer.NthBy(1, ageLess, &result)
+	assertEquals(t, exist, true)
+	assertEquals(t, result.Age, 15)
+	exist = streamer.NthBy(2, ageLess, &result)
+	assertEquals(t, exist, true)
+	assertEquals(t, result.Age, 20)
+
+	exist = streamer.NthBy(4, ageLess, &result)
+	assertEquals(t, exist, false)
+
+	data := make([]int, 1000)
+	for i := 0; i < len(data); i++ {
+		data[i] = (i * 7919) % 1000
+	}
+	nth := 0
+	OfSlice(data).NthBy(123, func(a, b int) bool {
+		return a < b
+	}, &nth)
+	assertEquals(t, nth, 123)
+}
+
+func nthData() []int {
+	data := make([]int, 100000)
+	for i := 0; i < len(data); i++ {
+		data[i] = (i * 7919) % len(data)
+	}
+	return data
+}
+
+func BenchmarkStreamerNthBy(b *testing.B) {
+	s := OfSlice(nthData())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nth := 0
+		s.NthBy(50000, func(a, b int) bool {
+			return a < b
+		}, &nth)
+	}
+}
+
+func BenchmarkStreamerSortedIndexAt(b *testing.B) {
+	s := OfSlice(nthData())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nth := 0
+		s.Sorted(func(a, b int) bool {
+			return a < b
+		}).IndexAt(50000, &nth)
+	}
+}
+
+func sortData() []int {
+	data := make([]int, 5000000)
+	for i := 0; i < len(data); i++ {
+		data[i] = (i * 7919) % len(data)
+	}
+	return data
+}
+
+func BenchmarkStreamerSorted5M(b *testing.B) {
+	data := sortData()
+	less := func(a, b int) bool {
+		return a < b
+	}
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			OfSlice(data).Sorted(less).Count()
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			OfSlice(data).ParallelUnbounded(8).Sorted(less).Count()
+		}
+	})
+}
+
+func TestStreamerParallelUnbounded(t *testing.T) {
+	data := make([]int, 100)
+	var running, maxRunning int64
+	mapper := func(elem int) int {
+		cur := atomic.AddInt64(&running, 1)
+		for {
+			max := atomic.LoadInt64(&maxRunning)
+			if cur <= max || atomic.CompareAndSwapInt64(&maxRunning, max, cur) {
+				break
+			}
+		}
+		// 等待所有worker都开始执行，worker不足100个时会等到超时
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt64(&maxRunning) < int64(len(data)) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		atomic.AddInt64(&running, -1)
+		return elem
+	}
+	OfSlice(data).ParallelUnbounded(100).Map(mapper).Count()
+	assertEquals(t, maxRunning, int64(100))
+}
+
+func TestStreamerAssertUnique(t *testing.T) {
+	idKeyer := func(elem testUser) int {
+		return elem.ID
+	}
+	if err := streamer.AssertUnique(idKeyer); err != nil {
+		t.Fatal(err)
+	}
+
+	data := append([]testUser{}, testData...)
+	data = append(data, testUser{ID: 2, Name: "duplicated"})
+	err := OfSlice(data).AssertUnique(idKeyer)
+	if err == nil {
+		t.Fatal("expected duplicate key error, but return nil")
+	}
+	assertEquals(t, err.Error(), "duplicate key 2: {2 lisi 15 lisi@xxx.com} and {2 duplicated 0 }")
+}
+
+func TestStreamerWithPool(t *testing.T) {
+	s := OfSlice(testData).Parallel(2).WithPool(2)
+	result := []int{}
+	s.Filter(func(elem testUser) bool {
+		return elem.Age >= 15
+	}).Map(func(elem testUser) int {
+		return elem.ID
+	}).FlatMap(func(elem int) []int {
+		return []int{elem}
+	}).Scan(&result)
+	assertEquals(t, result, []int{1, 2, 3, 4})
+	pool := s.(*SliceStreamer).pool
+	pool.mu.Lock()
+	if pool.workers > 2 {
+		t.Errorf("expected at most 2 workers, but %d", pool.workers)
+	}
+	pool.mu.Unlock()
+
+	// 在pool的worker中再次使用同一个pool，worker都在忙时不会死锁
+	nested := []int{}
+	s.Map(func(elem testUser) int {
+		return s.Filter(func(inner testUser) bool {
+			return inner.Age == elem.Age
+		}).Count()
+	}).Scan(&nested)
+	assertEquals(t, nested, []int{2, 2, 1, 1})
+}
+
+func largePipeline(s SliceStream) {
+	s.Filter(func(elem int) bool {
+		return elem%2 == 0
+	}).Map(func(elem int) int {
+		return elem + 1
+	}).Filter(func(elem int) bool {
+		return elem%3 != 0
+	}).Map(func(elem int) int {
+		return elem * 2
+	}).Count()
+}
+
+func BenchmarkStreamerMultiStage(b *testing.B) {
+	s := OfSlice(make([]int, 100000)).Parallel(2)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		largePipeline(s)
+	}
+}
+
+func BenchmarkStreamerMultiStageWithPool(b *testing.B) {
+	s := OfSlice(make([]int, 100000)).Parallel(2).WithPool(2)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		largePipeline(s)
+	}
+}
+
+func TestStreamerMapAll(t *testing.T) {
+	result := []float64{}
+	streamer.MapAll(func(items []testUser) []float64 {
+		min, max := items[0].Age, items[0].Age
+		for _, item := range items {
+			if item.Age < min {
+				min = item.Age
+			}
+			if item.Age > max {
+				max = item.Age
+			}
+		}
+		normalized := make([]float64, 0, len(items))
+		for _, item := range items {
+			normalized = append(normalized, float64(item.Age-min)/float64(max-min))
+		}
+		return normalized
+	}).Scan(&result)
+	assertEquals(t, result, []float64{0, 0, 0.5, 1})
+}
+
+func TestStreamerShortCircuit(t *testing.T) {
+	data := make([]int, 1000000)
+	for i := 0; i < len(data); i++ {
+		data[i] = i
+	}
+	calls := 0
+	first := 0
+	exist := OfSlice(data).Filter(func(elem int) bool {
+		calls++
+		return elem%10 == 9
+	}).Map(func(elem int) int {
+		return elem * 2
+	}).First(&first)
+	assertEquals(t, exist, true)
+	assertEquals(t, first, 18)
+	assertEquals(t, calls, 10)
+
+	calls = 0
+	matched := OfSlice(data).Peek(func(elem int) {
+		calls++
+	}).AnyMatch(func(elem int) bool {
+		return elem == 5
+	})
+	assertEquals(t, matched, true)
+	assertEquals(t, calls, 6)
+
+	result := []int{}
+	OfSlice(data).FlatMap(func(elem int) []int {
+		return []int{elem, elem}
+	}).Distinct().Offset(1).Limit(3).Scan(&result)
+	assertEquals(t, result, []int{1, 2, 3})
+
+	// Sorted需要全部数据，退化为完整的scan
+	calls = 0
+	OfSlice(data[:100]).Peek(func(elem int) {
+		calls++
+	}).Sorted(func(a, b int) bool {
+		return a > b
+	}).First(&first)
+	assertEquals(t, first, 99)
+	assertEquals(t, calls, 100)
+}
+
+func TestStreamerScanEach(t *testing.T) {
+	result := []testUser{}
+	ageSum := 0
+	streamer.ScanEach(func(elem testUser) {
+		ageSum += elem.Age
+	}, &result)
+	assertEquals(t, result, testData)
+	assertEquals(t, ageSum, 75)
+
+	// op在对应的元素写入result之后立即执行
+	written := []int{}
+	index := 0
+	OfSlice([]int{1, 2, 3}).ScanEach(func(elem int) {
+		assertEquals(t, written[index], elem)
+		index++
+	}, &written)
+	assertEquals(t, written, []int{1, 2, 3})
+	assertEquals(t, index, 3)
+
+	if Try(func() { streamer.ScanEach(func(elem int) {}, &result) }) == nil {
+		t.Error("expected panic for mismatched op type")
+	}
+}
+
+func BenchmarkStreamerMap10M(b *testing.B) {
+	s := OfSlice(make([]int, 10000000)).Parallel(2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Filter(func(elem int) bool {
+			return elem >= 0
+		}).Map(func(elem int) int {
+			return elem + 1
+		}).Count()
+	}
+}
+
+func TestStreamerCommonSignatures(t *testing.T) {
+	// func(string) bool / func(string) int 走快速路径，func(testUser) bool 走反射
+	result := []int{}
+	OfSlice([]string{"a", "bb", "ccc"}).Filter(func(elem string) bool {
+		return len(elem) > 1
+	}).Map(func(elem string) int {
+		return len(elem)
+	}).Scan(&result)
+	assertEquals(t, result, []int{2, 3})
+
+	errs := []error{}
+	OfSlice([]string{"1", "x"}).Parallel(2).WithWorkStealing().Map(strconv.Atoi).ScanCollectErrors(&result, &errs)
+	assertEquals(t, result, []int{1})
+	assertEquals(t, len(errs), 1)
+}
+
+func TestStreamerOfSliceArray(t *testing.T) {
+	arr := [3]int{1, 2, 3}
+	result := []int{}
+	OfSlice(arr).Map(func(elem int) int {
+		return elem * 2
+	}).Scan(&result)
+	assertEquals(t, result, []int{2, 4, 6})
+
+	s := OfSlice(&arr)
+	arr[0] = 10
+	s.Scan(&result)
+	assertEquals(t, result, []int{10, 2, 3})
+
+	slicePtr := []int{4, 5}
+	OfSlice(&slicePtr).Scan(&result)
+	assertEquals(t, result, []int{4, 5})
+
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok {
+			t.Fatalf("expected OfSlice to panic with error, but got %v", r)
+		}
+		assertEquals(t, err.Error(), "data must be slice, array or pointer to them, not map[int]int")
+	}()
+	OfSlice(map[int]int{1: 1})
+}
+
+// seqNilSafetyCase 由seq_test.go在go1.23及以上版本中设置，检查Seq对nil元素的处理
+var seqNilSafetyCase func(t *testing.T)
+
+func TestStreamerNilSafety(t *testing.T) {
+	if seqNilSafetyCase != nil {
+		t.Run("Seq", seqNilSafetyCase)
+	}
+	var nilSlice []int
+	result := []int{}
+	OfSlice(nilSlice).Scan(&result)
+	assertEquals(t, result, []int{})
+	var nilSlicePtr *[]int
+	assertEquals(t, OfSlice(nilSlicePtr).Count(), 0)
+	var nilArrayPtr *[3]int
+	assertEquals(t, OfSlice(nilArrayPtr).Count(), 0)
+
+	u := testData[0]
+	users := []*testUser{nil, &u}
+	names := []string{}
+	OfSlice(users).Parallel(2).Map(func(elem *testUser) string {
+		if elem == nil {
+			return "<nil>"
+		}
+		return elem.Name
+	}).Scan(&names)
+	assertEquals(t, names, []string{"<nil>", "zhangsan"})
+	first := &testUser{}
+	exist := OfSlice(users).First(&first)
+	assertEquals(t, exist, true)
+	assertEquals(t, first == nil, true)
+
+	// 元素类型为接口时，nil元素以nil interface{}保存
+	values := []interface{}{nil, 1, nil, "a"}
+	nonNil := []interface{}{}
+	OfSlice(values).Filter(func(elem interface{}) bool {
+		return elem != nil
+	}).Scan(&nonNil)
+	assertEquals(t, nonNil, []interface{}{1, "a"})
+	all := []interface{}{}
+	OfSlice(values).Sorted(func(a, b interface{}) bool {
+		return a == nil && b != nil
+	}).Peek(func(elem interface{}) {}).Scan(&all)
+	assertEquals(t, all, []interface{}{nil, nil, 1, "a"})
+	errs := []error{nil, errors.New("bad")}
+	count := 0
+	OfSlice(errs).Limit(2).Foreach(func(err error) {
+		if err != nil {
+			count++
+		}
+	})
+	assertEquals(t, count, 1)
+
+	isNil := func(elem interface{}) bool {
+		return elem == nil
+	}
+	groups := map[bool][]interface{}{}
+	OfSlice(values).GroupBy(isNil, &groups)
+	assertEquals(t, groups, map[bool][]interface{}{true: {nil, nil}, false: {1, "a"}})
+	byNil := map[bool]interface{}{}
+	OfSlice(values).ToMap(isNil, &byNil)
+	assertEquals(t, byNil, map[bool]interface{}{true: nil, false: "a"})
+}
+
+func BenchmarkOfSlice(b *testing.B) {
+	data := make([]int, 5000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		OfSlice(data)
+	}
+}
+
+func TestStreamerOfSliceLazy(t *testing.T) {
+	data := []int{1, 2, 3}
+	s := OfSlice(data)
+	data[0] = 10
+	result := []int{}
+	s.Scan(&result)
+	assertEquals(t, result, []int{10, 2, 3})
+
+	s.ScanAndRelease(&result)
+	s.Scan(&result)
+	assertEquals(t, result, []int{})
+	assertEquals(t, data, []int{10, 2, 3})
+}
+
+func TestStreamerCumulativeMax(t *testing.T) {
+	result := []int{}
+	OfSlice([]int{1, 3, 2, 5, 4, 5}).CumulativeMax(func(a, b int) bool {
+		return a < b
+	}, &result)
+	assertEquals(t, result, []int{1, 3, 3, 5, 5, 5})
+
+	OfSlice([]int{}).CumulativeMax(func(a, b int) bool {
+		return a < b
+	}, &result)
+	assertEquals(t, result, []int{})
+}
+
+func TestOfChannel(t *testing.T) {
+	ch := make(chan testUser)
+	go func() {
+		for _, user := range testData {
+			ch <- user
+		}
+		close(ch)
+	}()
+	s := OfChannel(ch)
+	result := []int{}
+	s.Filter(func(elem testUser) bool {
+		return elem.Age > 15
+	}).Map(func(elem testUser) int {
+		return elem.ID
+	}).Scan(&result)
+	assertEquals(t, result, []int{3, 4})
+	assertEquals(t, s.Count(), 4)
+
+	var recvOnly <-chan int = make(chan int)
+	OfChannel(recvOnly)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for send-only channel")
+		}
+	}()
+	OfChannel(make(chan<- int))
+}
+
+func TestStreamerToChannel(t *testing.T) {
+	ch := make(chan int)
+	go streamer.Map(func(elem testUser) int {
+		return elem.ID
+	}).ToChannelClose(ch)
+	result := []int{}
+	for id := range ch {
+		result = append(result, id)
+	}
+	assertEquals(t, result, []int{1, 2, 3, 4})
+
+	buffered := make(chan int, 4)
+	streamer.Map(func(elem testUser) int {
+		return elem.Age
+	}).Sorted(func(a, b int) bool {
+		return a > b
+	}).ToChannel(buffered)
+	assertEquals(t, len(buffered), 4)
+	assertEquals(t, <-buffered, 25)
+}
+
+func TestOfRange(t *testing.T) {
+	result := []int{}
+	OfRange(0, 10, 3).Scan(&result)
+	assertEquals(t, result, []int{0, 3, 6, 9})
+	OfRange(5, 0, -2).Scan(&result)
+	assertEquals(t, result, []int{5, 3, 1})
+	assertEquals(t, OfRange(3, 3, 1).Count(), 0)
+	assertEquals(t, OfRange(0, 5, -1).Count(), 0)
+	OfRange(1, 100, 1).Filter(func(elem int) bool {
+		return elem%10 == 0
+	}).Map(func(elem int) int {
+		return elem / 10
+	}).Scan(&result)
+	assertEquals(t, result, []int{1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for zero step")
+		}
+	}()
+	OfRange(0, 10, 0)
+}
+
+func TestSymmetricDifference(t *testing.T) {
+	result := []int{}
+	SymmetricDifference(OfSlice([]int{1, 2, 3, 4}), OfSlice([]int{3, 4, 5, 6, 3}), func(elem int) int {
+		return elem
+	}, &result)
+	assertEquals(t, result, []int{1, 2, 5, 6})
+
+	users := []testUser{}
+	SymmetricDifference(streamer, OfSlice([]testUser{{ID: 2}, {ID: 7}}), func(elem testUser) int {
+		return elem.ID
+	}, &users)
+	ids := []int{}
+	OfSlice(users).Map(func(elem testUser) int {
+		return elem.ID
+	}).Scan(&ids)
+	assertEquals(t, ids, []int{1, 3, 4, 7})
+}
+
+func TestIterate(t *testing.T) {
+	result := []int{}
+	Iterate(1, func(x int) int {
+		return x * 2
+	}).Limit(10).Scan(&result)
+	assertEquals(t, result, []int{1, 2, 4, 8, 16, 32, 64, 128, 256, 512})
+
+	strs := []string{}
+	Iterate(1, func(x int) int {
+		return x + 1
+	}).Filter(func(x int) bool {
+		return x%3 == 0
+	}).Offset(1).Limit(3).Map(func(x int) string {
+		return strconv.Itoa(x)
+	}).Scan(&strs)
+	assertEquals(t, strs, []string{"6", "9", "12"})
+
+	first := 0
+	Iterate(1, func(x int) int {
+		return x + 1
+	}).Filter(func(x int) bool {
+		return x > 100
+	}).First(&first)
+	assertEquals(t, first, 101)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for unbounded terminal")
+		}
+	}()
+	Iterate(1, func(x int) int {
+		return x + 1
+	}).Count()
+}
+
+func TestStreamerScanPage(t *testing.T) {
+	result := []int{}
+	total := OfRange(0, 50, 1).ScanPage(20, 10, &result)
+	assertEquals(t, total, 50)
+	assertEquals(t, result, []int{20, 21, 22, 23, 24, 25, 26, 27, 28, 29})
+	total = OfRange(0, 50, 1).ScanPage(45, 10, &result)
+	assertEquals(t, total, 50)
+	assertEquals(t, result, []int{45, 46, 47, 48, 49})
+	total = OfRange(0, 50, 1).Filter(func(elem int) bool {
+		return elem%10 == 0
+	}).ScanPage(60, 10, &result)
+	assertEquals(t, total, 5)
+	assertEquals(t, result, []int{})
+}
+
+func TestFromJSON(t *testing.T) {
+	s, err := FromJSON([]byte(`[{"ID":1,"Name":"a","Age":10},{"ID":2,"Name":"b","Age":20}]`), (*testUser)(nil))
+	assertEquals(t, err, nil)
+	names := []string{}
+	s.Filter(func(elem testUser) bool {
+		return elem.Age > 15
+	}).Map(func(elem testUser) string {
+		return elem.Name
+	}).Scan(&names)
+	assertEquals(t, names, []string{"b"})
+
+	s, err = FromJSON([]byte(`[1, 2`), (*int)(nil))
+	if err == nil || s != nil {
+		t.Error("expected error for malformed json")
+	}
+}
+
+func TestStreamerToJSON(t *testing.T) {
+	data, err := streamer.Filter(func(elem testUser) bool {
+		return elem.ID == 1
+	}).ToJSON()
+	assertEquals(t, err, nil)
+	assertEquals(t, string(data), `[{"ID":1,"Name":"zhangsan","Age":15,"Email":"zhangsan@xxx.com"}]`)
+
+	data, err = OfRange(0, 0, 1).ToJSON()
+	assertEquals(t, err, nil)
+	assertEquals(t, string(data), `[]`)
+
+	_, err = OfSlice([]func(){func() {}}).ToJSON()
+	if err == nil {
+		t.Error("expected error for unsupported type")
+	}
+}
+
+func TestOfLines(t *testing.T) {
+	s := OfLines(strings.NewReader("INFO start\nERROR disk full\r\nINFO done\nERROR timeout"))
+	result := []string{}
+	s.Filter(func(elem string) bool {
+		return strings.HasPrefix(elem, "ERROR")
+	}).Map(func(elem string) string {
+		return strings.TrimPrefix(elem, "ERROR ")
+	}).Scan(&result)
+	assertEquals(t, result, []string{"disk full", "timeout"})
+	assertEquals(t, s.Count(), 4)
+
+	long := strings.Repeat("x", 100)
+	OfLines(strings.NewReader(long+"\n"), 200).Scan(&result)
+	assertEquals(t, result, []string{long})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for too long line")
+		}
+	}()
+	OfLines(strings.NewReader(long), 50).Count()
+}
+
+func TestStreamerTry(t *testing.T) {
+	result := []int{}
+	err := Try(func() {
+		OfSlice([]int{1, 2, 3}).Filter(func(a, b int) bool {
+			return a > b
+		}).Scan(&result)
+	})
+	if err == nil || !strings.Contains(err.Error(), "args number") {
+		t.Errorf("expected args number error, but got %v", err)
+	}
+
+	err = OfSlice([]int{3, 1, 2}).Sorted(func(a, b int) bool {
+		panic("bad comparator")
+	}).TryScan(&result)
+	assertEquals(t, err.Error(), "panic: bad comparator")
+
+	err = OfSlice([]int{1, 2, 3, 4}).Parallel(2).Map(func(elem int) int {
+		return 10 / (elem - 4)
+	}).TryScan(&result)
+	if err == nil || !strings.Contains(err.Error(), "divide by zero") {
+		t.Errorf("expected divide by zero error, but got %v", err)
+	}
+
+	err = streamer.TryScan(&result)
+	if err == nil {
+		t.Error("expected type mismatch error")
+	}
+	count, err := streamer.TryCount()
+	assertEquals(t, count, 4)
+	assertEquals(t, err, nil)
+	err = streamer.TryForeach(func(elem int) {})
+	if err == nil {
+		t.Error("expected type mismatch error")
+	}
+	assertEquals(t, streamer.TryForeach(func(elem testUser) {}), nil)
+}
+
+func TestStreamerPanicStack(t *testing.T) {
+	errBadItem := errors.New("bad item")
+	err := OfSlice([]int{1, 2, 3, 4}).Parallel(2).Filter(func(elem int) bool {
+		if elem == 4 {
+			panic(errBadItem)
+		}
+		return true
+	}).TryScan(&[]int{})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected PanicError, but got %v", err)
+	}
+	assertEquals(t, panicErr.Value, errBadItem)
+	assertEquals(t, errors.Is(err, errBadItem), true)
+	if !strings.Contains(string(panicErr.Stack), "slice_stream_test.go") {
+		t.Errorf("expected stack to contain the callback's file, but got %s", panicErr.Stack)
+	}
+	if !strings.Contains(err.Error(), "slice_stream_test.go") {
+		t.Error("expected error message to contain the stack")
+	}
+
+	err = OfSlice([]int{1, 2, 3, 4}).WithWorkStealing().Parallel(2).Map(func(elem int) int {
+		panic("stealing")
+	}).TryScan(&[]int{})
+	if !errors.As(err, &panicErr) || !strings.Contains(string(panicErr.Stack), "slice_stream_test.go") {
+		t.Errorf("expected PanicError with stack, but got %v", err)
+	}
+
+	// 多个goroutine同时panic时只保留一个，go test -race不应报告数据竞争
+	err = OfSlice([]int{1, 2, 3, 4, 5, 6, 7, 8}).ParallelUnbounded(4).Map(func(elem int) int {
+		panic(errBadItem)
+	}).TryScan(&[]int{})
+	assertEquals(t, errors.Is(err, errBadItem), true)
+	err = Try(func() {
+		OfMap(map[int]int{1: 1, 2: 2, 3: 3, 4: 4}).Parallel(2).Filter(func(k, v int) bool {
+			panic(errBadItem)
+		}).Count()
+	})
+	assertEquals(t, errors.Is(err, errBadItem), true)
+}
+
+func TestStreamerMapIndexed(t *testing.T) {
+	rows := []string{}
+	streamer.Filter(func(elem testUser) bool {
+		return elem.Age > 15
+	}).MapIndexed(func(index int, elem testUser) string {
+		return strconv.Itoa(index) + ":" + elem.Name
+	}).Scan(&rows)
+	assertEquals(t, rows, []string{"0:wangwu", "1:zhaoliu"})
+
+	OfSlice(testData).Parallel(2).Sorted(func(a, b testUser) bool {
+		return a.Age > b.Age
+	}).MapIndexed(func(index int, elem testUser) string {
+		return strconv.Itoa(index+1) + ":" + elem.Name
+	}).Limit(2).Scan(&rows)
+	assertEquals(t, rows, []string{"1:zhaoliu", "2:wangwu"})
+
+	first := ""
+	streamer.MapIndexed(func(index int, elem testUser) string {
+		return strconv.Itoa(index) + ":" + elem.Name
+	}).Offset(2).First(&first)
+	assertEquals(t, first, "2:wangwu")
+}
+
+func TestStreamerFilterIndexed(t *testing.T) {
+	result := []int{}
+	OfRange(10, 20, 1).FilterIndexed(func(index int, elem int) bool {
+		return index%2 == 0
+	}).Scan(&result)
+	assertEquals(t, result, []int{10, 12, 14, 16, 18})
+
+	names := []string{}
+	OfLines(strings.NewReader("name\nzhangsan\nlisi")).FilterIndexed(func(index int, elem string) bool {
+		return index > 0
+	}).Scan(&names)
+	assertEquals(t, names, []string{"zhangsan", "lisi"})
+
+	OfRange(0, 10, 1).Filter(func(elem int) bool {
+		return elem%3 != 0
+	}).FilterIndexed(func(index int, elem int) bool {
+		return index%2 == 1
+	}).Limit(2).Scan(&result)
+	assertEquals(t, result, []int{2, 5})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic for non-int index")
+		}
+	}()
+	OfRange(0, 10, 1).FilterIndexed(func(index int64, elem int) bool {
+		return true
+	})
+}
+
+func TestStreamerCache(t *testing.T) {
+	var calls int32
+	cached := OfSlice(testData).Parallel(2).Map(func(elem testUser) int {
+		atomic.AddInt32(&calls, 1)
+		return elem.Age
+	}).Cache()
+	assertEquals(t, cached.Count(), 4)
+	groups := map[int][]int{}
+	cached.GroupBy(func(age int) int {
+		return age
+	}, &groups)
+	assertEquals(t, len(groups[15]), 2)
+	result := []int{}
+	cached.Filter(func(age int) bool {
+		return age > 15
+	}).Scan(&result)
+	assertEquals(t, result, []int{20, 25})
+	assertEquals(t, atomic.LoadInt32(&calls), int32(4))
+
+	var wg sync.WaitGroup
+	calls = 0
+	concurrent := OfRange(0, 100, 1).Peek(func(elem int) {
+		atomic.AddInt32(&calls, 1)
+	}).Cache()
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertEquals(t, concurrent.Count(), 100)
+		}()
+	}
+	wg.Wait()
+	assertEquals(t, atomic.LoadInt32(&calls), int32(100))
 }
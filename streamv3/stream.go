@@ -1,17 +1,246 @@
 package streamv3
 
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+)
+
+// Group GroupByStream产出的分组，Key为keyer的返回值，Items为该分组下的元素（保持原有顺序）
+type Group struct {
+	Key   interface{}
+	Items []interface{}
+}
+
+// ReportRow Report产出的报表行，Key为分组的key，Values为每个聚合函数的名称和结果
+type ReportRow struct {
+	Key    interface{}
+	Values map[string]interface{}
+}
+
+// Entry MapStream中的一个键值对，SortedByKey/SortedByValue等产出的SliceStream以Entry为元素
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+var entryType = reflect.TypeOf(Entry{})
+
+// PanicError 并行执行时goroutine中发生的panic，会在主goroutine中重新panic
+// Value为原始的panic值，Stack为发生panic的goroutine的调用栈；Value为error时可以通过errors.As/errors.Is取出
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// newPanicError 需要在recover所在的defer函数中调用，才能取到发生panic时的调用栈
+func newPanicError(r interface{}) *PanicError {
+	return &PanicError{
+		Value: r,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
 type DataGetter interface {
 	getData() []interface{}
+	// release 释放源数据，释放后getData返回空数据
+	release()
+}
+
+// eachGetter 可以逐个读取元素的数据源，iterate时优先使用each而不是getData
+type eachGetter interface {
+	// each 依次将元素交给yield，yield返回false时停止
+	each(yield func(elem interface{}) bool)
+}
+
+// freshGetter getData每次都返回新分配的slice的数据源，scanCollectErrors可以直接在返回的slice上处理，不需要再复制一次；
+// 没有实现freshGetter的数据源返回的是缓存的数据，需要先复制再处理
+type freshGetter interface {
+	fresh() bool
 }
 
 type sliceGetter struct {
 	data []interface{}
+	// val OfSlice传入的原始slice，不为空时在getData时才逐个读取元素，避免创建stream时复制整个slice
+	val reflect.Value
+	// source 通过OfSource创建时的数据源名称，用于MarshalPlan
+	source string
 }
 
 func (getter *sliceGetter) getData() []interface{} {
+	if !getter.val.IsValid() {
+		return getter.data
+	}
+	data := make([]interface{}, getter.val.Len())
+	for i := 0; i < len(data); i++ {
+		data[i] = getter.val.Index(i).Interface()
+	}
+	return data
+}
+
+// each 逐个读取元素，OfSlice创建时直接从val读取，避免只需要前几个元素时转换整个slice
+func (getter *sliceGetter) each(yield func(elem interface{}) bool) {
+	if !getter.val.IsValid() {
+		for i := 0; i < len(getter.data) && yield(getter.data[i]); i++ {
+		}
+		return
+	}
+	for i := 0; i < getter.val.Len() && yield(getter.val.Index(i).Interface()); i++ {
+	}
+}
+
+// fresh 只有通过OfSlice创建时才在getData时逐个读取元素，否则返回的是OfSource等保存的data
+func (getter *sliceGetter) fresh() bool {
+	return getter.val.IsValid()
+}
+
+func (getter *sliceGetter) release() {
+	getter.data = nil
+	getter.val = reflect.Value{}
+}
+
+// channelGetter 第一次getData时读取channel直到关闭，之后返回缓存的数据
+type channelGetter struct {
+	ch   reflect.Value
+	once sync.Once
+	data []interface{}
+}
+
+func (getter *channelGetter) getData() []interface{} {
+	getter.once.Do(func() {
+		for {
+			elem, ok := getter.ch.Recv()
+			if !ok {
+				break
+			}
+			getter.data = append(getter.data, elem.Interface())
+		}
+	})
 	return getter.data
 }
 
+// each 第一次读取时边从channel接收边交给yield，yield返回false后仍会读完channel并缓存，之后的读取使用缓存的数据
+func (getter *channelGetter) each(yield func(elem interface{}) bool) {
+	received := false
+	getter.once.Do(func() {
+		received = true
+		stopped := false
+		for {
+			elem, ok := getter.ch.Recv()
+			if !ok {
+				break
+			}
+			getter.data = append(getter.data, elem.Interface())
+			if !stopped && !yield(elem.Interface()) {
+				stopped = true
+			}
+		}
+	})
+	if received {
+		return
+	}
+	for i := 0; i < len(getter.data) && yield(getter.data[i]); i++ {
+	}
+}
+
+func (getter *channelGetter) release() {
+	// 确保channel已经被读取过，release之后不会再读取
+	getter.once.Do(func() {})
+	getter.data = nil
+}
+
+// rangeGetter 每次getData时生成[start, end)之间步长为step的整数
+type rangeGetter struct {
+	start, end, step int
+	released         bool
+}
+
+func (getter *rangeGetter) getData() []interface{} {
+	if getter.released {
+		return nil
+	}
+	var data []interface{}
+	if getter.step > 0 && getter.start < getter.end {
+		data = make([]interface{}, 0, (getter.end-getter.start+getter.step-1)/getter.step)
+	} else if getter.step < 0 && getter.start > getter.end {
+		data = make([]interface{}, 0, (getter.start-getter.end-getter.step-1)/-getter.step)
+	}
+	for i := getter.start; (getter.step > 0 && i < getter.end) || (getter.step < 0 && i > getter.end); i += getter.step {
+		data = append(data, i)
+	}
+	return data
+}
+
+func (getter *rangeGetter) fresh() bool {
+	return true
+}
+
+func (getter *rangeGetter) release() {
+	getter.released = true
+}
+
+// iterateGetter 从seed开始不断调用next生成元素的无界数据源，只能通过each逐个读取
+type iterateGetter struct {
+	seed     reflect.Value
+	next     reflect.Value
+	released bool
+}
+
+func (getter *iterateGetter) getData() []interface{} {
+	if getter.released {
+		return nil
+	}
+	panic(errors.New("unbounded stream must be bounded by Limit before terminal operation"))
+}
+
+func (getter *iterateGetter) each(yield func(elem interface{}) bool) {
+	if getter.released {
+		return
+	}
+	for cur := getter.seed; yield(cur.Interface()); cur = getter.next.Call([]reflect.Value{cur})[0] {
+	}
+}
+
+func (getter *iterateGetter) release() {
+	getter.released = true
+}
+
+// linesGetter 第一次getData时按行读取reader直到结束，之后返回缓存的数据
+type linesGetter struct {
+	scanner *bufio.Scanner
+	once    sync.Once
+	data    []interface{}
+}
+
+func (getter *linesGetter) getData() []interface{} {
+	getter.once.Do(func() {
+		for getter.scanner.Scan() {
+			getter.data = append(getter.data, getter.scanner.Text())
+		}
+		if err := getter.scanner.Err(); err != nil {
+			panic(err)
+		}
+	})
+	return getter.data
+}
+
+func (getter *linesGetter) release() {
+	getter.once.Do(func() {})
+	getter.data = nil
+}
+
 type mapGetter struct {
 	steamer *MapStreamer
 }
@@ -19,3 +248,58 @@ type mapGetter struct {
 func (getter *mapGetter) getData() []interface{} {
 	return getter.steamer.scan()
 }
+
+func (getter *mapGetter) fresh() bool {
+	return true
+}
+
+func (getter *mapGetter) release() {
+	root := getter.steamer
+	for root.lastStreamer != nil {
+		root = root.lastStreamer
+	}
+	root.pairData = nil
+}
+
+// cacheGetter 第一次getData时执行streamer所在的整条链并缓存结果，之后返回缓存的数据
+// 并发调用getData时只会执行一次，缓存的数据只读，下游的操作会先复制再处理
+type cacheGetter struct {
+	streamer *SliceStreamer
+	once     sync.Once
+	data     []interface{}
+}
+
+func (getter *cacheGetter) getData() []interface{} {
+	getter.once.Do(func() {
+		getter.data = getter.streamer.scan()
+	})
+	return getter.data
+}
+
+func (getter *cacheGetter) release() {
+	getter.once.Do(func() {})
+	getter.data = nil
+	streamerList := getter.streamer.chain()
+	streamerList[len(streamerList)-1].dataGetter.release()
+}
+
+type concatGetter struct {
+	first  *SliceStreamer
+	second *SliceStreamer
+}
+
+func (getter *concatGetter) getData() []interface{} {
+	data := getter.first.scan()
+	return append(data[:len(data):len(data)], getter.second.scan()...)
+}
+
+func (getter *concatGetter) fresh() bool {
+	return true
+}
+
+func (getter *concatGetter) release() {
+	for _, streamer := range []*SliceStreamer{getter.first, getter.second} {
+		streamerList := streamer.chain()
+		streamerList[len(streamerList)-1].dataGetter.release()
+	}
+}